@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// atomContentType is the MIME type of Atom feeds.
+const atomContentType = "application/atom+xml"
+
+// atomFeed is an Atom feed of races, each race being an entry.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+}
+
+// newRacesFeedHandler returns the handler of GET /v1/races.atom, rendering the races listed by
+// listUpcomingRaces as an Atom feed, ordered by their start time.
+func newRacesFeedHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, racingClient racing.RacingClient, closedGrace time.Duration) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx := r.Context()
+
+		races, err := listUpcomingRaces(ctx, r, racingClient, closedGrace)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", atomContentType)
+
+		if _, err := w.Write([]byte(xml.Header)); err != nil {
+			log.Printf("failed writing races feed: %s\n", err)
+			return
+		}
+
+		if err := xml.NewEncoder(w).Encode(racesFeed(baseURL(r), races)); err != nil {
+			log.Printf("failed writing races feed: %s\n", err)
+		}
+	}
+}
+
+// listUpcomingRaces lists the races requested by the query parameters as for any other GET
+// endpoint (e.g. filter.meeting_ids=1), listing only upcoming races unless the filter asks for
+// other statuses. Upcoming races include those closed within the grace window, so they don't
+// vanish from feeds the moment they close. Every matching race is listed, unless the filter
+// asks for a limit.
+func listUpcomingRaces(ctx context.Context, r *http.Request, racingClient racing.RacingClient, closedGrace time.Duration) ([]*racing.Race, error) {
+	req := &racing.ListRacesRequest{}
+	if err := runtime.PopulateQueryParameters(req, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.Filter == nil {
+		req.Filter = &racing.ListRacesRequestFilter{}
+	}
+
+	if len(req.Filter.Statuses) == 0 {
+		req.Filter.Statuses = []racing.RaceStatus{racing.RaceStatus_OPEN}
+
+		if req.Filter.ClosedGraceSeconds == 0 {
+			req.Filter.ClosedGraceSeconds = int64(closedGrace / time.Second)
+		}
+	}
+
+	// Feeds list races, so neither their IDs nor their total alone would do.
+	req.IdsOnly = false
+	req.CountOnly = false
+
+	if req.Filter.Limit <= 0 {
+		return listAllRaces(ctx, racingClient, req)
+	}
+
+	resp, err := racingClient.ListRaces(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Races, nil
+}
+
+// racesFeed returns the feed of the races, linking each to its resource under base. The feed
+// was last updated at the latest start time of its races, as races are its entries.
+func racesFeed(base string, races []*racing.Race) *atomFeed {
+	feed := &atomFeed{
+		ID:      base + "/v1/races.atom",
+		Title:   "Upcoming races",
+		Author:  atomAuthor{Name: "Racing"},
+		Link:    atomLink{Rel: "self", Href: base + "/v1/races.atom"},
+		Entries: []atomEntry{},
+	}
+
+	var updated time.Time
+
+	for _, race := range races {
+		// Every entry must have been updated at some time, which is its start time.
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		start := race.AdvertisedStartTime.AsTime()
+		if start.After(updated) {
+			updated = start
+		}
+
+		href := base + "/v1/races/" + strconv.FormatInt(race.Id, 10)
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      href,
+			Title:   race.Name,
+			Updated: start.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: href},
+		})
+	}
+
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	return feed
+}
+
+// baseURL returns the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestRacesFeed(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	at := func(minutes int) *racing.Race {
+		advertisedStart, _ := ptypes.TimestampProto(start.Add(time.Duration(minutes) * time.Minute))
+		return &racing.Race{AdvertisedStartTime: advertisedStart}
+	}
+
+	races := []*racing.Race{at(0), at(30), {}, at(10)}
+	for i, race := range races {
+		race.Id = int64(i + 1)
+		race.Name = "Race <" + strings.Repeat("&", i) + ">"
+	}
+
+	racingClient := &pagedRacingClient{races: races}
+	handler := newRacesFeedHandler(runtime.NewServeMux(), newJSONMarshaler(true), racingClient, time.Minute)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "http://example.com/v1/races.atom", nil), nil)
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != atomContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, atomContentType)
+	}
+
+	if !strings.HasPrefix(rec.Body.String(), xml.Header) {
+		t.Error("feed is missing its XML declaration")
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed isn't valid XML: %s", err)
+	}
+
+	if feed.XMLName.Space != "http://www.w3.org/2005/Atom" || feed.ID != "http://example.com/v1/races.atom" {
+		t.Errorf("feed %s in namespace %q, want an Atom feed", feed.ID, feed.XMLName.Space)
+	}
+
+	// Race 3 has no start time to be updated at, so isn't an entry.
+	var got []string
+	for _, entry := range feed.Entries {
+		got = append(got, entry.ID+" "+entry.Title+" "+entry.Updated)
+	}
+
+	want := []string{
+		"http://example.com/v1/races/1 Race <> 2030-01-15T02:00:00Z",
+		"http://example.com/v1/races/2 Race <&> 2030-01-15T02:30:00Z",
+		"http://example.com/v1/races/4 Race <&&&> 2030-01-15T02:10:00Z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("feed entries = %q, want %q", got, want)
+	}
+
+	// The feed was last updated as its last race starts.
+	if feed.Updated != "2030-01-15T02:30:00Z" {
+		t.Errorf("feed updated = %s, want 2030-01-15T02:30:00Z", feed.Updated)
+	}
+
+	// Upcoming races are listed by default, along with those closed within the grace window.
+	filter := racingClient.requests[0].Filter
+	if !reflect.DeepEqual(filter.Statuses, []racing.RaceStatus{racing.RaceStatus_OPEN}) || filter.ClosedGraceSeconds != 60 {
+		t.Errorf("ListRaces() filter = %v, want open races with a grace of 60s", filter)
+	}
+}
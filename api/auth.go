@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyAuth authenticates clients by the API key they send, limiting the rate of requests
+// each key makes.
+type apiKeyAuth struct {
+	// rates are the requests per second each known key may make, or 0 for no limit.
+	rates map[string]float64
+	// burst is how many requests a key may make at once, having made none for a while.
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket is the allowance of requests a key has left, as of when it was last updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newAPIKeyAuth creates an authenticator accepting the keys, at their rates, allowing bursts of
+// up to burst requests.
+func newAPIKeyAuth(rates map[string]float64, burst int) *apiKeyAuth {
+	return &apiKeyAuth{rates: rates, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// loadAPIKeys reads the API keys in the file, one per line, each allowed the default rate of
+// requests per second unless listed as key=rate. Blank lines and lines starting with # are
+// ignored.
+func loadAPIKeys(path string, defaultRate float64) (map[string]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rates := make(map[string]float64)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		// Keys are sensitive, so they're never included in the error.
+		key, rate := entry, defaultRate
+		if i := strings.Index(entry, "="); i >= 0 {
+			key = strings.TrimSpace(entry[:i])
+
+			if rate, err = strconv.ParseFloat(strings.TrimSpace(entry[i+1:]), 64); err != nil || rate < 0 {
+				return nil, fmt.Errorf("invalid API key on line %d: rate must be requests per second, or 0 for no limit", line)
+			}
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid API key on line %d: key is empty", line)
+		}
+
+		rates[key] = rate
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no API keys in %q", path)
+	}
+
+	return rates, nil
+}
+
+// apiKeyKey is the context key of the API key a request was authenticated by.
+type apiKeyKey struct{}
+
+// requireAPIKey rejects requests without a known API key with Unauthenticated, and those beyond
+// their key's rate with ResourceExhausted, rendered as by the mux. The key of each request let
+// through is added to its context.
+func requireAPIKey(next http.Handler, auth *apiKeyAuth, mux *runtime.ServeMux, marshaler runtime.Marshaler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+
+		rate, ok := auth.rates[key]
+		if !ok {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.Unauthenticated, "missing or unknown API key"))
+			return
+		}
+
+		if wait := auth.take(key, rate, time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.ResourceExhausted, "API key rate limit exceeded"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, key)))
+	})
+}
+
+// authenticatedKey returns the API key the request was authenticated by, if it was.
+func authenticatedKey(r *http.Request) (string, bool) {
+	key, ok := r.Context().Value(apiKeyKey{}).(string)
+	return key, ok
+}
+
+// take claims a request of the key's allowance as at now, returning 0 if it had one left, or
+// otherwise how long until it will. Keys without a rate are never limited.
+func (a *apiKeyAuth) take(key string, rate float64, now time.Time) time.Duration {
+	if rate == 0 {
+		return 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	burst := math.Max(a.burst, 1)
+
+	bucket, ok := a.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		a.buckets[key] = bucket
+	}
+
+	// The allowance refills at the key's rate, up to the burst.
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+
+	bucket.tokens--
+
+	return 0
+}
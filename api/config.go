@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// redacted replaces the value of any sensitive flag in the effective configuration.
+const redacted = "[REDACTED]"
+
+// sensitiveFlagNames holds substrings identifying flags whose values must never be exposed.
+var sensitiveFlagNames = []string{"key", "secret", "token", "password"}
+
+// effectiveConfig returns the value of every flag in the set, with sensitive values redacted.
+func effectiveConfig(flags *flag.FlagSet) map[string]string {
+	config := make(map[string]string)
+
+	flags.VisitAll(func(f *flag.Flag) {
+		config[f.Name] = f.Value.String()
+
+		for _, sensitive := range sensitiveFlagNames {
+			if strings.Contains(strings.ToLower(f.Name), sensitive) {
+				config[f.Name] = redacted
+			}
+		}
+	})
+
+	return config
+}
+
+// newConfigHandler returns the handler of GET /v1/config, serving the effective configuration
+// of the flags as JSON to clients sending one of the admin API keys in X-API-Key. Others are
+// rejected with Unauthenticated, or PermissionDenied when their key isn't an admin key, rendered
+// as by the mux.
+func newConfigHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, flags *flag.FlagSet, adminKeys map[string]float64) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.Unauthenticated, "missing API key"))
+			return
+		}
+
+		if _, ok := adminKeys[key]; !ok {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.PermissionDenied, "API key isn't an admin key"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(effectiveConfig(flags)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// newTestFlags returns a flag set holding secrets among its other flags.
+func newTestFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("api-endpoint", "localhost:8000", "")
+	flags.String("tls-key-file", "/etc/api/tls.key", "")
+	flags.String("Webhook-Secret", "hunter2", "")
+	flags.String("upstream-token", "abc123", "")
+	flags.String("db-password", "swordfish", "")
+	flags.Bool("gzip", true, "")
+
+	return flags
+}
+
+func TestEffectiveConfigRedactsSecrets(t *testing.T) {
+	want := map[string]string{
+		"api-endpoint":   "localhost:8000",
+		"tls-key-file":   redacted,
+		"Webhook-Secret": redacted,
+		"upstream-token": redacted,
+		"db-password":    redacted,
+		"gzip":           "true",
+	}
+
+	if got := effectiveConfig(newTestFlags()); !reflect.DeepEqual(got, want) {
+		t.Errorf("effectiveConfig() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	marshaler := newJSONMarshaler(true)
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler))
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/config", newConfigHandler(mux, marshaler, newTestFlags(), map[string]float64{"admin-key": 0})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{name: "admin key", key: "admin-key", wantStatus: http.StatusOK},
+		{name: "other key", key: "client-key", wantStatus: http.StatusForbidden},
+		{name: "no key", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/config", nil)
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GET /v1/config status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var config map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&config); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range []string{"tls-key-file", "Webhook-Secret", "upstream-token", "db-password"} {
+				if config[name] != redacted {
+					t.Errorf("GET /v1/config %s = %q, want %q", name, config[name], redacted)
+				}
+			}
+		})
+	}
+}
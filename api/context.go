@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// raceContextEventsWindow is how close to the start of a race sports events must start to be
+// part of its context. Meetings have no venue to match the venues of events against, so nearby
+// events are those nearby in time.
+const raceContextEventsWindow = time.Hour
+
+// raceContext is the response of the race context endpoint. Each section is marshalled by the JSON
+// marshaler, so resources are shaped as they are by every other endpoint.
+type raceContext struct {
+	Race         json.RawMessage   `json:"race"`
+	Meeting      json.RawMessage   `json:"meeting"`
+	SiblingRaces []json.RawMessage `json:"siblingRaces"`
+	Events       []json.RawMessage `json:"events"`
+	// Warnings describes the sections left empty because their backend failed.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// newRaceContextHandler returns the handler of GET /v1/races/{id}/context, returning a race
+// along with its meeting, the other races of the meeting, and the sports events starting near
+// it. Racing failures fail the request, while the events are omitted with a warning should the
+// sports backend fail.
+func newRaceContextHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, racingClient racing.RacingClient, sportsClient sports.SportsClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx := r.Context()
+
+		id, err := strconv.ParseInt(pathParams["id"], 10, 64)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid race id %q", pathParams["id"]))
+			return
+		}
+
+		body, err := fetchRaceContext(ctx, marshaler, racingClient, sportsClient, id)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			log.Printf("failed writing race context: %s\n", err)
+		}
+	}
+}
+
+// fetchRaceContext fetches the race, then fans out to fetch the rest of its context at once.
+func fetchRaceContext(ctx context.Context, marshaler runtime.Marshaler, racingClient racing.RacingClient, sportsClient sports.SportsClient, id int64) (*raceContext, error) {
+	race, err := racingClient.GetRace(ctx, &racing.GetRaceRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg                    sync.WaitGroup
+		meetings              *racing.ListMeetingsResponse
+		siblings              []*racing.Race
+		events                []*sports.Event
+		meetingsErr, racesErr error
+		eventsErr             error
+	)
+
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		meetings, meetingsErr = racingClient.ListMeetings(ctx, &racing.ListMeetingsRequest{})
+	}()
+
+	go func() {
+		defer wg.Done()
+		siblings, racesErr = listAllRaces(ctx, racingClient, &racing.ListRacesRequest{
+			Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{race.MeetingId}},
+		})
+	}()
+
+	go func() {
+		defer wg.Done()
+		events, eventsErr = listNearbyEvents(ctx, sportsClient, race)
+	}()
+
+	wg.Wait()
+
+	if meetingsErr != nil {
+		return nil, meetingsErr
+	}
+	if racesErr != nil {
+		return nil, racesErr
+	}
+
+	body := &raceContext{SiblingRaces: []json.RawMessage{}, Events: []json.RawMessage{}}
+
+	if body.Race, err = marshaler.Marshal(race); err != nil {
+		return nil, err
+	}
+
+	body.Meeting = json.RawMessage("null")
+
+	for _, meeting := range meetings.Meetings {
+		if meeting.Id == race.MeetingId {
+			if body.Meeting, err = marshaler.Marshal(meeting); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, sibling := range siblings {
+		if sibling.Id == race.Id {
+			continue
+		}
+
+		if body.SiblingRaces, err = appendMarshalled(marshaler, body.SiblingRaces, sibling); err != nil {
+			return nil, err
+		}
+	}
+
+	if eventsErr != nil {
+		log.Printf("failed listing events near race %d: %s\n", race.Id, eventsErr)
+		body.Warnings = append(body.Warnings, "sports events are unavailable: "+status.Convert(eventsErr).Message())
+
+		return body, nil
+	}
+
+	for _, event := range events {
+		if body.Events, err = appendMarshalled(marshaler, body.Events, event); err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
+
+// listNearbyEvents lists the visible sports events starting within the events window of the
+// race, every one of them however many pages they span. A race without a start time has no
+// nearby events.
+func listNearbyEvents(ctx context.Context, sportsClient sports.SportsClient, race *racing.Race) ([]*sports.Event, error) {
+	if race.AdvertisedStartTime == nil {
+		return nil, nil
+	}
+
+	start := race.AdvertisedStartTime.AsTime()
+
+	after, err := ptypes.TimestampProto(start.Add(-raceContextEventsWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := ptypes.TimestampProto(start.Add(raceContextEventsWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	return listAllEvents(ctx, sportsClient, &sports.ListEventsRequest{
+		Filter: &sports.ListEventsRequestFilter{
+			VisibleOnly:     true,
+			StartTimeAfter:  after,
+			StartTimeBefore: before,
+		},
+	})
+}
+
+func appendMarshalled(marshaler runtime.Marshaler, list []json.RawMessage, message proto.Message) ([]json.RawMessage, error) {
+	b, err := marshaler.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(list, b), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// contextRacingClient serves the races of a single meeting, along with every meeting.
+type contextRacingClient struct {
+	*pagedRacingClient
+	meetings []*racing.Meeting
+}
+
+func (c *contextRacingClient) GetRace(_ context.Context, in *racing.GetRaceRequest, _ ...grpc.CallOption) (*racing.Race, error) {
+	for _, race := range c.races {
+		if race.Id == in.Id {
+			return race, nil
+		}
+	}
+
+	return nil, status.Errorf(codes.NotFound, "race %d not found", in.Id)
+}
+
+func (c *contextRacingClient) ListMeetings(context.Context, *racing.ListMeetingsRequest, ...grpc.CallOption) (*racing.ListMeetingsResponse, error) {
+	return &racing.ListMeetingsResponse{Meetings: c.meetings}, nil
+}
+
+// resourceIDs returns the IDs of the marshalled resources, in order.
+func resourceIDs(t *testing.T, resources []json.RawMessage) []string {
+	t.Helper()
+
+	ids := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		var fields struct{ ID string }
+		if err := json.Unmarshal(resource, &fields); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, fields.ID)
+	}
+
+	return ids
+}
+
+func TestFetchRaceContext(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	advertisedStart, _ := ptypes.TimestampProto(start)
+
+	racingClient := &contextRacingClient{
+		pagedRacingClient: &pagedRacingClient{races: []*racing.Race{
+			{Id: 1, MeetingId: 5, AdvertisedStartTime: advertisedStart},
+			{Id: 2, MeetingId: 5},
+			{Id: 3, MeetingId: 5},
+		}},
+		meetings: []*racing.Meeting{{Id: 4, Name: "Randwick"}, {Id: 5, Name: "Flemington"}},
+	}
+
+	tests := []struct {
+		name         string
+		sportsClient sports.SportsClient
+		wantEvents   []string
+		wantWarning  bool
+	}{
+		{name: "every section", sportsClient: &pagedSportsClient{events: []*sports.Event{{Id: 7}, {Id: 8}}}, wantEvents: []string{"7", "8"}},
+		// The rest of the context is still returned should sports be down.
+		{name: "sports down", sportsClient: unavailableSportsClient{}, wantEvents: []string{}, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := fetchRaceContext(context.Background(), newJSONMarshaler(true), racingClient, tt.sportsClient, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var race, meeting struct {
+				ID   string
+				Name string
+			}
+			if err := json.Unmarshal(body.Race, &race); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(body.Meeting, &meeting); err != nil {
+				t.Fatal(err)
+			}
+
+			if race.ID != "1" || meeting.Name != "Flemington" {
+				t.Errorf("fetchRaceContext() race %s of meeting %q, want race 1 of Flemington", race.ID, meeting.Name)
+			}
+
+			// The race isn't its own sibling.
+			if got, want := resourceIDs(t, body.SiblingRaces), []string{"2", "3"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("fetchRaceContext() sibling races = %v, want %v", got, want)
+			}
+
+			if got := resourceIDs(t, body.Events); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("fetchRaceContext() events = %v, want %v", got, tt.wantEvents)
+			}
+
+			if got := len(body.Warnings) > 0; got != tt.wantWarning {
+				t.Errorf("fetchRaceContext() warnings = %v, want a warning %t", body.Warnings, tt.wantWarning)
+			}
+		})
+	}
+
+	// Events are those visible and starting within the window of the race.
+	sportsClient := &pagedSportsClient{}
+	if _, err := fetchRaceContext(context.Background(), newJSONMarshaler(true), racingClient, sportsClient, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := sportsClient.requests[0].Filter
+	if !filter.VisibleOnly || !filter.StartTimeAfter.AsTime().Equal(start.Add(-raceContextEventsWindow)) || !filter.StartTimeBefore.AsTime().Equal(start.Add(raceContextEventsWindow)) {
+		t.Errorf("ListEvents() filter = %v, want visible events starting within %s of %s", filter, raceContextEventsWindow, start)
+	}
+
+	// Racing failures fail the request.
+	if _, err := fetchRaceContext(context.Background(), newJSONMarshaler(true), racingClient, sportsClient, 9); status.Code(err) != codes.NotFound {
+		t.Errorf("fetchRaceContext() of an unknown race code = %v, want %v", status.Code(err), codes.NotFound)
+	}
+}
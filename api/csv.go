@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// csvContentType is the MIME type clients accept to receive responses as CSV.
+const csvContentType = "text/csv"
+
+// csvMarshaler renders responses as CSV, with a header row followed by one row per item of the
+// list in the response, such as its races. Nested messages are flattened into a column per
+// field, named by their path (e.g. "advertisedStartTime" or "filter.meetingIds"). Responses
+// without a list, such as errors, are rendered as a single row.
+//
+// Only responses are CSV, so requests are still unmarshalled using the JSON marshaler.
+type csvMarshaler struct {
+	runtime.Marshaler
+}
+
+// newCSVMarshaler returns the CSV marshaler used for responses, unmarshalling requests with
+// the given JSON marshaler.
+func newCSVMarshaler(json runtime.Marshaler) runtime.Marshaler {
+	return &csvMarshaler{Marshaler: json}
+}
+
+// ContentType returns the CSV content type, regardless of what's being marshalled.
+func (c *csvMarshaler) ContentType(_ interface{}) string {
+	return csvContentType
+}
+
+// Marshal renders the message v as CSV.
+func (c *csvMarshaler) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unable to marshal %T as CSV", v)
+	}
+
+	header, rows := csvTable(message.ProtoReflect())
+
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewEncoder returns an encoder writing each message as CSV.
+func (c *csvMarshaler) NewEncoder(w io.Writer) runtime.Encoder {
+	return runtime.EncoderFunc(func(v interface{}) error {
+		b, err := c.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	})
+}
+
+// csvTable returns the header and rows of the list within message. The list is the first
+// populated repeated field, falling back to the first repeated message field of an otherwise
+// empty message, so an empty list still has its header.
+func csvTable(message protoreflect.Message) ([]string, [][]string) {
+	list := csvListField(message)
+	if list == nil {
+		return csvColumns("", message.Descriptor()), [][]string{csvRow(message)}
+	}
+
+	var (
+		header []string
+		rows   [][]string
+		items  = message.Get(list).List()
+	)
+
+	if list.Kind() == protoreflect.MessageKind {
+		header = csvColumns("", list.Message())
+	} else {
+		header = []string{list.JSONName()}
+	}
+
+	for i := 0; i < items.Len(); i++ {
+		if list.Kind() == protoreflect.MessageKind {
+			rows = append(rows, csvRow(items.Get(i).Message()))
+		} else {
+			rows = append(rows, []string{csvScalar(list, items.Get(i))})
+		}
+	}
+
+	return header, rows
+}
+
+func csvListField(message protoreflect.Message) protoreflect.FieldDescriptor {
+	var (
+		list      protoreflect.FieldDescriptor
+		populated bool
+	)
+
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		populated = populated || message.Has(field)
+
+		if !field.IsList() {
+			continue
+		}
+
+		if message.Has(field) {
+			return field
+		}
+
+		if list == nil && field.Kind() == protoreflect.MessageKind {
+			list = field
+		}
+	}
+
+	if populated {
+		return nil
+	}
+
+	return list
+}
+
+// csvColumns returns the flattened column names of a message, prefixed by the path to it.
+func csvColumns(prefix string, message protoreflect.MessageDescriptor) []string {
+	var columns []string
+
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		name := prefix + field.JSONName()
+
+		if csvFlattened(field) {
+			columns = append(columns, csvColumns(name+".", field.Message())...)
+		} else {
+			columns = append(columns, name)
+		}
+	}
+
+	return columns
+}
+
+// csvRow returns the flattened values of a message, in the order of its columns.
+func csvRow(message protoreflect.Message) []string {
+	var row []string
+
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+
+		switch {
+		case csvFlattened(field):
+			row = append(row, csvRow(message.Get(field).Message())...)
+		case field.IsList():
+			var values []string
+
+			items := message.Get(field).List()
+			for j := 0; j < items.Len(); j++ {
+				values = append(values, csvScalar(field, items.Get(j)))
+			}
+
+			row = append(row, strings.Join(values, ";"))
+		default:
+			row = append(row, csvScalar(field, message.Get(field)))
+		}
+	}
+
+	return row
+}
+
+// csvFlattened reports whether field is a singular message with its own columns. Well-known
+// types, such as timestamps and wrappers, are instead rendered as a single value.
+func csvFlattened(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.MessageKind &&
+		!field.IsList() &&
+		!field.IsMap() &&
+		!strings.HasPrefix(string(field.Message().FullName()), "google.protobuf.")
+}
+
+// csvScalar renders a single value of field.
+func csvScalar(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch {
+	case field.IsMap():
+		return ""
+	case field.Kind() == protoreflect.EnumKind:
+		if enum := field.Enum().Values().ByNumber(value.Enum()); enum != nil {
+			return string(enum.Name())
+		}
+
+		return fmt.Sprint(value.Enum())
+	case field.Kind() == protoreflect.MessageKind:
+		return csvWellKnown(value.Message())
+	case field.Kind() == protoreflect.BytesKind:
+		return fmt.Sprintf("%x", value.Bytes())
+	default:
+		return value.String()
+	}
+}
+
+// csvWellKnown renders a well-known message, with unset messages rendered as an empty value.
+func csvWellKnown(message protoreflect.Message) string {
+	if !message.IsValid() {
+		return ""
+	}
+
+	fields := message.Descriptor().Fields()
+
+	switch message.Descriptor().FullName() {
+	case "google.protobuf.Timestamp":
+		seconds := message.Get(fields.ByName("seconds")).Int()
+		nanos := message.Get(fields.ByName("nanos")).Int()
+
+		return time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano)
+	}
+
+	// Wrappers hold a single value, while other messages fall back to their JSON form.
+	if fields.Len() == 1 && fields.Get(0).Name() == "value" {
+		return csvScalar(fields.Get(0), message.Get(fields.Get(0)))
+	}
+
+	b, err := protojson.Marshal(message.Interface())
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestCSVListsRaces(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	advertisedStart, _ := ptypes.TimestampProto(start)
+
+	json := newJSONMarshaler(true)
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, json),
+		runtime.WithMarshalerOption(csvContentType, newCSVMarshaler(json)),
+	)
+
+	racingServer := &fakeRacingServer{races: []*racing.Race{
+		{Id: 1, MeetingId: 2, Name: "Race 1", Number: 3, Visible: true, AdvertisedStartTime: advertisedStart, Status: racing.RaceStatus_OPEN},
+		{Id: 2, Name: "Race, with a comma"},
+	}}
+	if err := racing.RegisterRacingHandlerServer(context.Background(), mux, racingServer); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/list-races", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", csvContentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != csvContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, csvContentType)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The header is followed by a row per race, with a column per field.
+	if len(records) != 3 {
+		t.Fatalf("CSV has %d records, want a header and 2 races", len(records))
+	}
+
+	header := records[0]
+	row := make(map[string]string, len(header))
+	for i, column := range header {
+		row[column] = records[1][i]
+	}
+
+	want := map[string]string{
+		"id":                  "1",
+		"meetingId":           "2",
+		"name":                "Race 1",
+		"number":              "3",
+		"visible":             "true",
+		"advertisedStartTime": "2030-01-15T02:00:00Z",
+		"status":              "OPEN",
+	}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("CSV %s = %q, want %q", column, row[column], value)
+		}
+	}
+
+	if got := records[2][1:3]; !reflect.DeepEqual(got, []string{"0", "Race, with a comma"}) {
+		t.Errorf("CSV second race meeting and name = %q, want the name quoted whole", got)
+	}
+}
+
+func TestCSVFlattensNestedFields(t *testing.T) {
+	update := &racing.WatchRacesResponse{
+		Race:           &racing.Race{Id: 1, Name: "Race 1", Status: racing.RaceStatus_CLOSED},
+		PreviousStatus: racing.RaceStatus_OPEN,
+	}
+
+	b, err := newCSVMarshaler(newJSONMarshaler(true)).Marshal(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses without a list are a single row.
+	if len(records) != 2 {
+		t.Fatalf("CSV has %d records, want a header and a row", len(records))
+	}
+
+	row := make(map[string]string, len(records[0]))
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+
+	want := map[string]string{
+		"race.id":                  "1",
+		"race.name":                "Race 1",
+		"race.status":              "CLOSED",
+		"race.advertisedStartTime": "",
+		"previousStatus":           "OPEN",
+		"removed":                  "false",
+	}
+	for column, value := range want {
+		if got, ok := row[column]; !ok || got != value {
+			t.Errorf("CSV %s = %q (present %t), want %q", column, got, ok, value)
+		}
+	}
+}
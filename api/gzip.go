@@ -0,0 +1,223 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipMinSize is the smallest response worth compressing, unless the client refuses responses
+// that aren't compressed.
+const gzipMinSize = 1024
+
+// compressResponses gzips responses for clients that accept gzip. Responses already encoded
+// upstream are passed through as is, so they're never compressed twice.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		accept := parseAcceptEncoding(r.Header.Values("Accept-Encoding"))
+		if !accept.gzip {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, force: !accept.identity}
+		defer gw.Close()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptEncoding is whether a client accepts gzip and unencoded (identity) responses.
+type acceptEncoding struct {
+	gzip     bool
+	identity bool
+}
+
+// parseAcceptEncoding parses the Accept-Encoding header values, tolerating the mangling some
+// proxies apply, such as odd casing, stray whitespace and empty entries. An encoding is accepted
+// when listed with a non-zero quality, or when unlisted and a wildcard has one. Identity is
+// accepted unless explicitly refused, by itself or by the wildcard.
+func parseAcceptEncoding(values []string) acceptEncoding {
+	qualities := make(map[string]float64)
+
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			params := strings.Split(entry, ";")
+
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			if coding == "" {
+				continue
+			}
+
+			// x-gzip is the legacy name of gzip.
+			if coding == "x-gzip" {
+				coding = "gzip"
+			}
+
+			qualities[coding] = acceptQuality(params[1:])
+		}
+	}
+
+	accepted := func(coding string, byDefault bool) bool {
+		if q, ok := qualities[coding]; ok {
+			return q > 0
+		}
+
+		if q, ok := qualities["*"]; ok {
+			return q > 0
+		}
+
+		return byDefault
+	}
+
+	return acceptEncoding{
+		gzip:     accepted("gzip", false),
+		identity: accepted("identity", true),
+	}
+}
+
+// acceptQuality returns the quality given by the q parameter, defaulting to 1. Malformed
+// qualities are treated as refusals.
+func acceptQuality(params []string) float64 {
+	for _, param := range params {
+		name, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			name, value = param[:i], param[i+1:]
+		}
+
+		if strings.ToLower(strings.TrimSpace(name)) != "q" {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 {
+			return 0
+		}
+
+		return q
+	}
+
+	return 1
+}
+
+// gzipResponseWriter buffers the start of a response until it can tell whether to compress it,
+// being once it's large enough to be worth compressing, or has ended.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	// force compresses responses of any size, for clients refusing unencoded responses.
+	force bool
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	if !g.decided {
+		g.buf = append(g.buf, b...)
+
+		if len(g.buf) < gzipMinSize {
+			return len(b), nil
+		}
+
+		if err := g.decide(); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+
+	return g.ResponseWriter.Write(b)
+}
+
+// Flush sends everything written so far, deciding whether to compress the response if it's yet
+// to be decided.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		_ = g.decide()
+	}
+
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close ends the response, flushing the buffered or compressed remainder of its body.
+func (g *gzipResponseWriter) Close() error {
+	if !g.decided {
+		if err := g.decide(); err != nil {
+			return err
+		}
+	}
+
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+
+	return nil
+}
+
+// decide writes the response header, compressing the response unless it's already encoded, has
+// no body, or is too small to be worth compressing, then writes the buffered body.
+func (g *gzipResponseWriter) decide() error {
+	g.decided = true
+
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	header := g.Header()
+
+	compress := header.Get("Content-Encoding") == "" &&
+		g.status != http.StatusNoContent &&
+		g.status != http.StatusNotModified &&
+		len(g.buf) > 0 &&
+		(g.force || len(g.buf) >= gzipMinSize)
+
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+
+	buf := g.buf
+	g.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(buf)
+	} else {
+		_, err = g.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
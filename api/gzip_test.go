@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   acceptEncoding
+	}{
+		{name: "none", want: acceptEncoding{identity: true}},
+		{name: "gzip", values: []string{"gzip"}, want: acceptEncoding{gzip: true, identity: true}},
+		{name: "mangled", values: []string{" GZip ;Q=0.5 ,, deflate"}, want: acceptEncoding{gzip: true, identity: true}},
+		{name: "across headers", values: []string{"deflate", "gzip"}, want: acceptEncoding{gzip: true, identity: true}},
+		{name: "legacy name", values: []string{"x-gzip"}, want: acceptEncoding{gzip: true, identity: true}},
+		{name: "refused", values: []string{"gzip;q=0"}, want: acceptEncoding{identity: true}},
+		{name: "malformed quality", values: []string{"gzip;q=high"}, want: acceptEncoding{identity: true}},
+		{name: "wildcard", values: []string{"*"}, want: acceptEncoding{gzip: true, identity: true}},
+		{name: "wildcard refused", values: []string{"*;q=0"}, want: acceptEncoding{}},
+		{name: "identity refused", values: []string{"gzip, identity;q=0"}, want: acceptEncoding{gzip: true}},
+		{name: "listed over wildcard", values: []string{"gzip;q=0, *"}, want: acceptEncoding{identity: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAcceptEncoding(tt.values); got != tt.want {
+				t.Errorf("parseAcceptEncoding(%q) = %+v, want %+v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressResponses(t *testing.T) {
+	large := strings.Repeat("race ", gzipMinSize)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		// encoding is the Content-Encoding set upstream, if any.
+		encoding string
+		body     string
+		wantGzip bool
+	}{
+		{name: "large", acceptEncoding: "gzip", body: large, wantGzip: true},
+		{name: "small", acceptEncoding: "gzip", body: "race", wantGzip: false},
+		{name: "not accepted", body: large, wantGzip: false},
+		// Responses already encoded upstream aren't compressed twice.
+		{name: "already encoded", acceptEncoding: "gzip", encoding: "br", body: large, wantGzip: false},
+		// Clients refusing unencoded responses have even small ones compressed.
+		{name: "identity refused", acceptEncoding: "gzip, identity;q=0", body: "race", wantGzip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := compressResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+
+				io.WriteString(w, tt.body)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/races/1", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", vary)
+			}
+
+			encoding := rec.Header().Get("Content-Encoding")
+			if got := encoding == "gzip"; got != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %t", encoding, tt.wantGzip)
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantGzip {
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if string(body) != tt.body {
+				t.Errorf("body = %d bytes, want the %d written", len(body), len(tt.body))
+			}
+		})
+	}
+}
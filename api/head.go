@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// totalCountHeader is the header list responses carry the total of matching results in,
+	// regardless of the page returned.
+	totalCountHeader = "X-Total-Count"
+
+	// checksumHeader is the header list responses carry the checksum of their results in, as
+	// the hex SHA-256 of the response message, so clients can tell whether they've changed
+	// without fetching them.
+	checksumHeader = "X-Result-Checksum"
+)
+
+// headListRoutes are the POST routes listing races and events, which HEAD requests run with an
+// empty filter.
+var headListRoutes = map[string]bool{
+	"/v1/list-races":  true,
+	"/v1/list-events": true,
+}
+
+// listResponse is a response listing a page of results.
+type listResponse interface {
+	proto.Message
+	GetTotal() int64
+	GetNextPageToken() string
+}
+
+// allowHead serves HEAD requests as the equivalent GET request, or for the list routes as a
+// POST with an empty filter. The server discards the body of a HEAD response, so clients
+// receive the same status and headers without the payload.
+func allowHead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			r = r.Clone(r.Context())
+			r.Method = http.MethodGet
+
+			if headListRoutes[r.URL.Path] {
+				r.Method = http.MethodPost
+				r.Body = ioutil.NopCloser(strings.NewReader("{}"))
+				r.ContentLength = 2
+				r.Header.Set("Content-Type", "application/json")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardListHeaders is a response modifier setting the total and checksum headers of list
+// responses.
+func forwardListHeaders(_ context.Context, w http.ResponseWriter, message proto.Message) error {
+	list, ok := message.(listResponse)
+	if !ok {
+		return nil
+	}
+
+	// Deterministic marshalling orders map entries, so the same results have the same checksum.
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(b)
+
+	w.Header().Set(totalCountHeader, strconv.FormatInt(list.GetTotal(), 10))
+	w.Header().Set(checksumHeader, hex.EncodeToString(checksum[:]))
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// fakeRacingServer serves a fixed list of races.
+type fakeRacingServer struct {
+	racing.UnimplementedRacingServer
+	races []*racing.Race
+}
+
+func (f *fakeRacingServer) ListRaces(context.Context, *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	return &racing.ListRacesResponse{Races: f.races, Total: int64(len(f.races))}, nil
+}
+
+func (f *fakeRacingServer) GetRace(_ context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	return &racing.Race{Id: in.Id, Name: "Race"}, nil
+}
+
+// fakeSportsServer serves a fixed list of events.
+type fakeSportsServer struct {
+	sports.UnimplementedSportsServer
+	events []*sports.Event
+}
+
+func (f *fakeSportsServer) ListEvents(context.Context, *sports.ListEventsRequest) (*sports.ListEventsResponse, error) {
+	return &sports.ListEventsResponse{Events: f.events, Total: int64(len(f.events))}, nil
+}
+
+func TestHeadRequests(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithForwardResponseOption(forwardListHeaders))
+
+	racingServer := &fakeRacingServer{races: []*racing.Race{{Id: 1, Name: "Race 1"}, {Id: 2, Name: "Race 2"}}}
+	if err := racing.RegisterRacingHandlerServer(context.Background(), mux, racingServer); err != nil {
+		t.Fatal(err)
+	}
+
+	sportsServer := &fakeSportsServer{events: []*sports.Event{{Id: 1, Name: "Event 1"}}}
+	if err := sports.RegisterSportsHandlerServer(context.Background(), mux, sportsServer); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(allowHead(mux))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		method    string
+		wantTotal string
+	}{
+		{name: "list races", path: "/v1/list-races", method: http.MethodPost, wantTotal: "2"},
+		{name: "list events", path: "/v1/list-events", method: http.MethodPost, wantTotal: "1"},
+		{name: "get race", path: "/v1/races/1", method: http.MethodGet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader("{}")
+			}
+
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want.Body.Close()
+
+			resp, err := http.Head(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("HEAD %s status = %d, want %d", tt.path, resp.StatusCode, http.StatusOK)
+			}
+
+			if b, err := ioutil.ReadAll(resp.Body); err != nil || len(b) != 0 {
+				t.Errorf("HEAD %s body = %q (error %v), want it empty", tt.path, b, err)
+			}
+
+			if got := resp.Header.Get(totalCountHeader); got != tt.wantTotal {
+				t.Errorf("HEAD %s %s = %q, want %q", tt.path, totalCountHeader, got, tt.wantTotal)
+			}
+
+			// The checksum is of the same results the equivalent request returns.
+			checksum := resp.Header.Get(checksumHeader)
+			if want := want.Header.Get(checksumHeader); checksum != want {
+				t.Errorf("HEAD %s %s = %q, want %q", tt.path, checksumHeader, checksum, want)
+			}
+
+			if listed := tt.wantTotal != ""; listed != (checksum != "") {
+				t.Errorf("HEAD %s %s = %q, want it set only for lists", tt.path, checksumHeader, checksum)
+			}
+		})
+	}
+}
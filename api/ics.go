@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+const (
+	// icsContentType is the MIME type of iCalendar files.
+	icsContentType = "text/calendar; charset=utf-8"
+
+	// icsDateTime is the layout of iCalendar date-times in UTC.
+	icsDateTime = "20060102T150405Z"
+
+	// icsLineLength is the most octets a content line may have, excluding its line break,
+	// before it must be folded.
+	icsLineLength = 75
+)
+
+// icsEscaper escapes the characters with special meaning in iCalendar text values.
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// newRacesCalendarHandler returns the handler of GET /v1/races.ics, rendering the races listed
+// by listUpcomingRaces as iCalendar events, ordered by their start time. Races have no duration,
+// so each event is an instant at the race's advertised start time.
+func newRacesCalendarHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, racingClient racing.RacingClient, closedGrace time.Duration) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx := r.Context()
+
+		races, err := listUpcomingRaces(ctx, r, racingClient, closedGrace)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", icsContentType)
+
+		if _, err := w.Write(racesCalendar(r.Host, races, time.Now())); err != nil {
+			log.Printf("failed writing races calendar: %s\n", err)
+		}
+	}
+}
+
+// racesCalendar returns the calendar of the races as at now, with an event for each race that
+// has a start time. Event UIDs are unique to the race and host, so re-importing the calendar
+// updates races rather than duplicating them.
+func racesCalendar(host string, races []*racing.Race, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//Entain//Racing//EN")
+
+	for _, race := range races {
+		if race.AdvertisedStartTime == nil {
+			continue
+		}
+
+		writeICSLine(&buf, "BEGIN:VEVENT")
+		writeICSLine(&buf, "UID:race-"+strconv.FormatInt(race.Id, 10)+"@"+host)
+		writeICSLine(&buf, "DTSTAMP:"+now.UTC().Format(icsDateTime))
+		writeICSLine(&buf, "DTSTART:"+race.AdvertisedStartTime.AsTime().UTC().Format(icsDateTime))
+		writeICSLine(&buf, "SUMMARY:"+icsEscaper.Replace(race.Name))
+		writeICSLine(&buf, "END:VEVENT")
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// writeICSLine writes a content line, folding it onto continuation lines starting with a space
+// where it's too long. Lines are only folded between UTF-8 characters, so none are split.
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := icsLineLength
+
+	for len(line) > limit {
+		split := limit
+		for split > 0 && !utf8.RuneStart(line[split]) {
+			split--
+		}
+
+		buf.WriteString(line[:split])
+		buf.WriteString("\r\n ")
+		line = line[split:]
+
+		// Continuation lines start with a space, which counts towards their length.
+		limit = icsLineLength - 1
+	}
+
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
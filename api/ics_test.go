@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// icsEvents parses the events of the calendar, being the properties of each unfolded into a map,
+// failing should the calendar not be valid iCalendar.
+func icsEvents(t *testing.T, calendar string) []map[string]string {
+	t.Helper()
+
+	if !strings.HasSuffix(calendar, "\r\n") {
+		t.Fatal("calendar doesn't end with a line break")
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(calendar, "\r\n"), "\r\n") {
+		if len(line) > icsLineLength {
+			t.Errorf("line %q is %d octets, want at most %d", line, len(line), icsLineLength)
+		}
+		if strings.Contains(line, "\n") {
+			t.Errorf("line %q has a bare line feed", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %q is folded within a character", line)
+		}
+
+		// Continuation lines are unfolded onto the line they continue.
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) < 2 || lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Fatalf("calendar lines %q aren't within a VCALENDAR", lines)
+	}
+
+	var (
+		events []map[string]string
+		event  map[string]string
+	)
+
+	for _, line := range lines[1 : len(lines)-1] {
+		name, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			name, value = line[:i], line[i+1:]
+		}
+
+		switch {
+		case line == "BEGIN:VEVENT" && event == nil:
+			event = make(map[string]string)
+		case line == "END:VEVENT" && event != nil:
+			for _, required := range []string{"UID", "DTSTAMP", "DTSTART"} {
+				if event[required] == "" {
+					t.Errorf("event %v is missing %s", event, required)
+				}
+			}
+
+			events = append(events, event)
+			event = nil
+		case event != nil:
+			event[name] = value
+		case name != "VERSION" && name != "PRODID":
+			t.Errorf("unexpected calendar line %q", line)
+		}
+	}
+
+	if event != nil {
+		t.Error("calendar ends within an event")
+	}
+
+	return events
+}
+
+func TestRacesCalendar(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	advertisedStart, _ := ptypes.TimestampProto(start)
+
+	// The long name is folded, but never within a character.
+	long := strings.Repeat("Ünïcödé ", 20)
+	races := []*racing.Race{
+		{Id: 1, Name: "Cup; Plate, and \\ more", AdvertisedStartTime: advertisedStart},
+		{Id: 2, Name: "No start time"},
+		{Id: 3, Name: long, AdvertisedStartTime: advertisedStart},
+	}
+
+	events := icsEvents(t, string(racesCalendar("example.com", races, start.Add(-time.Hour))))
+
+	want := []map[string]string{
+		{"UID": "race-1@example.com", "DTSTAMP": "20300115T010000Z", "DTSTART": "20300115T020000Z", "SUMMARY": `Cup\; Plate\, and \\ more`},
+		{"UID": "race-3@example.com", "DTSTAMP": "20300115T010000Z", "DTSTART": "20300115T020000Z", "SUMMARY": long},
+	}
+
+	// Race 2 has no start time, so isn't an event.
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("calendar events = %v, want %v", events, want)
+	}
+}
+
+func TestRacesCalendarHandler(t *testing.T) {
+	start, _ := ptypes.TimestampProto(time.Now().Add(time.Hour))
+
+	racingClient := &pagedRacingClient{races: []*racing.Race{
+		{Id: 1, Name: "Race 1", AdvertisedStartTime: start},
+		{Id: 2, Name: "Race 2", AdvertisedStartTime: start},
+	}}
+	handler := newRacesCalendarHandler(runtime.NewServeMux(), newJSONMarshaler(true), racingClient, 0)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "http://example.com/v1/races.ics", nil), nil)
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != icsContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, icsContentType)
+	}
+
+	if events := icsEvents(t, rec.Body.String()); len(events) != 2 {
+		t.Errorf("calendar has %d events, want 2", len(events))
+	}
+}
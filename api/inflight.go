@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyHeader is the header clients identify themselves by.
+const apiKeyHeader = "X-API-Key"
+
+// inFlightLimiter bounds the requests each client has in flight at once. Clients are the API
+// key they were authenticated by, or otherwise their IP address, so clients without a key share
+// their address' allowance.
+type inFlightLimiter struct {
+	// limit is the allowance of clients without one of their own, or 0 for no limit.
+	limit int
+	// limits are the allowances of particular API keys, overriding limit.
+	limits map[string]int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// newInFlightLimiter creates a limiter allowing each client limit requests in flight at once,
+// or any number when 0, other than the API keys given their own limits.
+func newInFlightLimiter(limit int, limits map[string]int) *inFlightLimiter {
+	return &inFlightLimiter{limit: limit, limits: limits, inFlight: make(map[string]int)}
+}
+
+// parseInFlightLimits parses API key allowances listed as key=limit pairs separated by commas,
+// such as "key1=10,key2=2".
+func parseInFlightLimits(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		// Keys are sensitive, so they're never included in the error.
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid in-flight limit: must be key=limit")
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid in-flight limit %q: must be a number of requests, or 0 for no limit", parts[1])
+		}
+
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return limits, nil
+}
+
+// limitInFlight rejects requests with ResourceExhausted, rendered as by the mux, while their
+// client already has its allowance of requests in flight.
+func limitInFlight(next http.Handler, limiter *inFlightLimiter, mux *runtime.ServeMux, marshaler runtime.Marshaler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, limit := limiter.clientOf(r)
+
+		if !limiter.acquire(client, limit) {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.ResourceExhausted, "too many requests in flight"))
+			return
+		}
+		defer limiter.release(client)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientOf returns the client making the request, being the API key it was authenticated by or
+// otherwise its IP address, and its allowance. Keys that weren't authenticated are ignored, so
+// clients can't escape their address' allowance by sending a new key with each request. Keys
+// and addresses are told apart, so neither can pass for the other.
+func (l *inFlightLimiter) clientOf(r *http.Request) (string, int) {
+	if key, ok := authenticatedKey(r); ok {
+		if limit, ok := l.limits[key]; ok {
+			return "key:" + key, limit
+		}
+
+		return "key:" + key, l.limit
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "addr:" + host, l.limit
+}
+
+// acquire claims one of the client's requests in flight, reporting whether it had one left
+// within its limit, where a limit of 0 is no limit.
+func (l *inFlightLimiter) acquire(client string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit > 0 && l.inFlight[client] >= limit {
+		return false
+	}
+
+	l.inFlight[client]++
+
+	return true
+}
+
+// release returns one of the client's requests in flight, forgetting clients with none left.
+func (l *inFlightLimiter) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight[client]--
+	if l.inFlight[client] <= 0 {
+		delete(l.inFlight, client)
+	}
+}
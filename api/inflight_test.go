@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	const requests = 5
+
+	tests := []struct {
+		name string
+		// keys are the API keys sent with each request, in turn. Keys are only authenticated when
+		// auth is set.
+		keys    []string
+		auth    bool
+		limit   int
+		limits  map[string]int
+		wantOK  int
+		wantErr int
+	}{
+		{name: "one key", keys: []string{"key1"}, auth: true, limit: 2, wantOK: 2, wantErr: 3},
+		{name: "key's own limit", keys: []string{"key1"}, auth: true, limit: 2, limits: map[string]int{"key1": 4}, wantOK: 4, wantErr: 1},
+		{name: "keys each have a limit", keys: []string{"key1", "key2"}, auth: true, limit: 2, wantOK: 4, wantErr: 1},
+		{name: "unauthenticated keys share the address", keys: []string{"a", "b", "c", "d", "e"}, limit: 2, wantOK: 2, wantErr: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marshaler := newJSONMarshaler(true)
+			mux := runtime.NewServeMux()
+
+			// Requests let through hold their allowance until released.
+			var (
+				entered = make(chan struct{}, requests)
+				release = make(chan struct{})
+			)
+
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				entered <- struct{}{}
+				<-release
+			})
+
+			handler = limitInFlight(handler, newInFlightLimiter(tt.limit, tt.limits), mux, marshaler)
+			if tt.auth {
+				rates := make(map[string]float64)
+				for _, key := range tt.keys {
+					rates[key] = 0
+				}
+
+				handler = requireAPIKey(handler, newAPIKeyAuth(rates, 1), mux, marshaler)
+			}
+
+			var (
+				wg    sync.WaitGroup
+				codes = make(chan int, requests)
+			)
+
+			for i := 0; i < requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/v1/meetings", nil)
+				req.Header.Set(apiKeyHeader, tt.keys[i%len(tt.keys)])
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, req)
+					codes <- rec.Code
+				}()
+			}
+
+			// Every request beyond the limits is rejected while those let through are in flight.
+			for i := 0; i < tt.wantOK; i++ {
+				<-entered
+			}
+
+			for i := 0; i < tt.wantErr; i++ {
+				select {
+				case code := <-codes:
+					if code != http.StatusTooManyRequests {
+						t.Errorf("request beyond the limit status = %d, want %d", code, http.StatusTooManyRequests)
+					}
+				case <-entered:
+					t.Error("request beyond the limit was let through")
+				}
+			}
+
+			close(release)
+			wg.Wait()
+			close(codes)
+
+			for code := range codes {
+				if code != http.StatusOK {
+					t.Errorf("request within the limit status = %d, want %d", code, http.StatusOK)
+				}
+			}
+		})
+	}
+}
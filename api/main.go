@@ -2,20 +2,60 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log"
 	"net/http"
 
 	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
-	apiEndpoint  = flag.String("api-endpoint", "localhost:8000", "API endpoint")
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	apiEndpoint     = flag.String("api-endpoint", "localhost:8000", "API endpoint")
+	grpcEndpoint    = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	sportsEndpoint  = flag.String("sports-grpc-endpoint", "localhost:9001", "Sports gRPC server endpoint")
+	emitUnpopulated = flag.Bool("emit-unpopulated", true, "Include zero-value fields in JSON responses, with unset messages as null, rather than omitting them")
+	admin           = flag.Bool("admin", false, "Expose admin-only endpoints, such as GET /v1/config, to clients with an admin API key")
+	adminKeysFile   = flag.String("admin-api-keys-file", "", "File of the API keys allowed admin-only endpoints, one per line, required by -admin")
+	gzipResponses   = flag.Bool("gzip", true, "Compress responses for clients accepting gzip")
+	closedGrace     = flag.Duration("closed-grace-window", 0, "How long races stay in the upcoming races feeds after closing")
+	maxInFlight     = flag.Int("max-in-flight", 0, "Maximum requests each client has in flight at once, by authenticated API key or IP address, or 0 for no limit")
+	inFlightByKey   = flag.String("in-flight-limits-by-key", "", "API keys allowed their own -max-in-flight, as key=limit pairs separated by commas")
+	serverTime      = flag.String("server-time-header", "X-Server-Time", "Response header list responses carry the server's current time in, or empty for none")
+	metricsEndpoint = flag.String("metrics-endpoint", "", "Endpoint serving Prometheus metrics at /metrics, or empty to disable")
+
+	apiKeysFile    = flag.String("api-keys-file", "", "File of the API keys clients must send in X-API-Key, one per line as key or key=rate, or empty to allow any client")
+	rateLimit      = flag.Float64("rate-limit", 0, "Requests per second each API key may make, unless given its own rate, or 0 for no limit")
+	rateLimitBurst = flag.Int("rate-limit-burst", 10, "Requests each API key may make at once, beyond -rate-limit")
+
+	tlsCertFile     = flag.String("tls-cert-file", "", "PEM certificate to serve HTTPS with, or empty to serve HTTP")
+	tlsKeyFile      = flag.String("tls-key-file", "", "PEM private key of -tls-cert-file")
+	grpcTLS         = flag.Bool("grpc-tls", false, "Dial the racing and sports servers over TLS")
+	grpcTLSCAFile   = flag.String("grpc-tls-ca-file", "", "PEM CA certificates verifying the racing and sports servers, or empty for the system's")
+	grpcTLSCertFile = flag.String("grpc-tls-cert-file", "", "PEM client certificate presented to the racing and sports servers, or empty for none")
+	grpcTLSKeyFile  = flag.String("grpc-tls-key-file", "", "PEM private key of -grpc-tls-cert-file")
 )
 
+// protobufContentType is the MIME type clients accept to receive binary protobuf responses.
+const protobufContentType = "application/x-protobuf"
+
+// protobufMarshaler marshals binary protobuf, labelled with the protobuf content type rather
+// than as an opaque octet stream.
+type protobufMarshaler struct {
+	runtime.ProtoMarshaller
+}
+
+// ContentType returns the protobuf content type, regardless of what's being marshalled.
+func (p *protobufMarshaler) ContentType(_ interface{}) string {
+	return protobufContentType
+}
+
 func main() {
 	flag.Parse()
 
@@ -29,17 +69,203 @@ func run() error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	mux := runtime.NewServeMux()
+	jsonMarshaler := newJSONMarshaler(*emitUnpopulated)
+
+	muxOptions := []runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, jsonMarshaler),
+		runtime.WithMarshalerOption(csvContentType, newCSVMarshaler(jsonMarshaler)),
+		// Binary protobuf, for clients that would rather not parse JSON. Requests sent with this
+		// content type are decoded as binary protobuf too.
+		runtime.WithMarshalerOption(protobufContentType, &protobufMarshaler{}),
+		runtime.WithForwardResponseOption(forwardListHeaders),
+	}
+	if *serverTime != "" {
+		muxOptions = append(muxOptions, runtime.WithForwardResponseOption(forwardServerTime(*serverTime)))
+	}
+
+	// Requests are only recorded when metrics are served, but they're logged regardless.
+	var gwMetrics *gatewayMetrics
+
+	if *metricsEndpoint != "" {
+		gwMetrics = newGatewayMetrics()
+		if err := prometheus.Register(gwMetrics); err != nil {
+			return err
+		}
+
+		go serveMetrics(*metricsEndpoint)
+	}
+
+	transportCreds, err := dialCredentials(*grpcTLS, *grpcTLSCAFile, *grpcTLSCertFile, *grpcTLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	// Every RPC carries the ID of the request it serves, so it can be found in the logs of
+	// the racing and sports servers too.
+	dialOptions := []grpc.DialOption{
+		transportCreds,
+		grpc.WithUnaryInterceptor(propagateRequestID(gwMetrics)),
+		grpc.WithStreamInterceptor(propagateStreamRequestID()),
+	}
+
+	mux := runtime.NewServeMux(muxOptions...)
 	if err := racing.RegisterRacingHandlerFromEndpoint(
 		ctx,
 		mux,
 		*grpcEndpoint,
-		[]grpc.DialOption{grpc.WithInsecure()},
+		dialOptions,
 	); err != nil {
 		return err
 	}
 
+	if err := sports.RegisterSportsHandlerFromEndpoint(
+		ctx,
+		mux,
+		*sportsEndpoint,
+		dialOptions,
+	); err != nil {
+		return err
+	}
+
+	racingConn, err := grpc.DialContext(ctx, *grpcEndpoint, dialOptions...)
+	if err != nil {
+		return err
+	}
+	defer racingConn.Close()
+
+	sportsConn, err := grpc.DialContext(ctx, *sportsEndpoint, dialOptions...)
+	if err != nil {
+		return err
+	}
+	defer sportsConn.Close()
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/races/{id}/context", newRaceContextHandler(
+		mux,
+		jsonMarshaler,
+		racing.NewRacingClient(racingConn),
+		sports.NewSportsClient(sportsConn),
+	)); err != nil {
+		return err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/races.atom", newRacesFeedHandler(
+		mux,
+		jsonMarshaler,
+		racing.NewRacingClient(racingConn),
+		*closedGrace,
+	)); err != nil {
+		return err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/races.ics", newRacesCalendarHandler(
+		mux,
+		jsonMarshaler,
+		racing.NewRacingClient(racingConn),
+		*closedGrace,
+	)); err != nil {
+		return err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/snapshot", newSnapshotHandler(
+		mux,
+		jsonMarshaler,
+		racing.NewRacingClient(racingConn),
+		sports.NewSportsClient(sportsConn),
+	)); err != nil {
+		return err
+	}
+
+	if err := mux.HandlePath(http.MethodGet, "/v1/upcoming", newUpcomingHandler(
+		mux,
+		jsonMarshaler,
+		racing.NewRacingClient(racingConn),
+		sports.NewSportsClient(sportsConn),
+	)); err != nil {
+		return err
+	}
+
+	if *admin {
+		// Admin endpoints are never exposed to just any client.
+		if *adminKeysFile == "" {
+			return errors.New("-admin requires -admin-api-keys-file")
+		}
+
+		adminKeys, err := loadAPIKeys(*adminKeysFile, 0)
+		if err != nil {
+			return err
+		}
+
+		if err := mux.HandlePath(http.MethodGet, "/v1/config", newConfigHandler(mux, jsonMarshaler, flag.CommandLine, adminKeys)); err != nil {
+			return err
+		}
+	}
+
 	log.Printf("API server listening on: %s\n", *apiEndpoint)
 
-	return http.ListenAndServe(*apiEndpoint, mux)
+	inFlightLimits, err := parseInFlightLimits(*inFlightByKey)
+	if err != nil {
+		return err
+	}
+
+	// Only authenticated keys are given their own allowance.
+	if len(inFlightLimits) > 0 && *apiKeysFile == "" {
+		return errors.New("-in-flight-limits-by-key requires -api-keys-file")
+	}
+
+	handler := allowHead(mux)
+	if *maxInFlight > 0 || len(inFlightLimits) > 0 {
+		handler = limitInFlight(handler, newInFlightLimiter(*maxInFlight, inFlightLimits), mux, jsonMarshaler)
+	}
+	// Clients are authenticated before their requests count towards their in-flight allowance.
+	if *apiKeysFile != "" {
+		rates, err := loadAPIKeys(*apiKeysFile, *rateLimit)
+		if err != nil {
+			return err
+		}
+
+		handler = requireAPIKey(handler, newAPIKeyAuth(rates, *rateLimitBurst), mux, jsonMarshaler)
+	}
+	if *gzipResponses {
+		handler = compressResponses(handler)
+	}
+
+	// Requests are observed outermost, so those rejected are logged too.
+	handler = observeRequests(handler, gwMetrics)
+
+	if *tlsCertFile != "" || *tlsKeyFile != "" {
+		return http.ListenAndServeTLS(*apiEndpoint, *tlsCertFile, *tlsKeyFile, handler)
+	}
+
+	return http.ListenAndServe(*apiEndpoint, handler)
+}
+
+// serveMetrics serves the registered Prometheus metrics at /metrics on the endpoint.
+func serveMetrics(endpoint string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Printf("metrics server listening on: %s\n", endpoint)
+
+	if err := http.ListenAndServe(endpoint, mux); err != nil {
+		log.Printf("failed serving metrics: %s\n", err)
+	}
+}
+
+// newJSONMarshaler returns the JSON marshaler used for responses.
+//
+// When emitUnpopulated is set, every field is present in the response, with unset messages
+// (e.g. a missing timestamp) rendered as an explicit null. This suits clients that expect a
+// fixed shape, but breaks those that can't handle nulls. When unset, zero-value fields are
+// omitted entirely, so clients must treat a missing field as its zero value.
+func newJSONMarshaler(emitUnpopulated bool) runtime.Marshaler {
+	return &runtime.HTTPBodyMarshaler{
+		Marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				EmitUnpopulated: emitUnpopulated,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		},
+	}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestJSONMarshalerEmitUnpopulated(t *testing.T) {
+	// The race is hidden, so visible is false, and has no start time.
+	race := &racing.Race{Id: 1, Name: "Race 1"}
+
+	tests := []struct {
+		name            string
+		emitUnpopulated bool
+		want            map[string]interface{}
+	}{
+		{
+			name:            "emitted",
+			emitUnpopulated: true,
+			want:            map[string]interface{}{"visible": false, "number": "0", "advertisedStartTime": nil},
+		},
+		{
+			name:            "omitted",
+			emitUnpopulated: false,
+			want:            map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newJSONMarshaler(tt.emitUnpopulated).Marshal(race)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range []string{"visible", "number", "advertisedStartTime"} {
+				value, ok := fields[field]
+				want, wantOK := tt.want[field]
+
+				if ok != wantOK || value != want {
+					t.Errorf("Marshal() %s = %v (present %t), want %v (present %t)", field, value, ok, want, wantOK)
+				}
+			}
+
+			// Populated fields are present either way.
+			if fields["name"] != race.Name {
+				t.Errorf("Marshal() name = %v, want %q", fields["name"], race.Name)
+			}
+		})
+	}
+}
+
+func TestProtobufResponses(t *testing.T) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, newJSONMarshaler(true)),
+		runtime.WithMarshalerOption(protobufContentType, &protobufMarshaler{}),
+	)
+
+	racingServer := &fakeRacingServer{races: []*racing.Race{{Id: 1, Name: "Race 1"}, {Id: 2, Name: "Race 2"}}}
+	if err := racing.RegisterRacingHandlerServer(context.Background(), mux, racingServer); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	request, err := proto.Marshal(&racing.ListRacesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        io.Reader
+	}{
+		{name: "JSON request", contentType: "application/json", body: strings.NewReader("{}")},
+		{name: "protobuf request", contentType: protobufContentType, body: bytes.NewReader(request)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/list-races", tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Accept", protobufContentType)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if contentType := resp.Header.Get("Content-Type"); contentType != protobufContentType {
+				t.Errorf("Content-Type = %q, want %q", contentType, protobufContentType)
+			}
+
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got racing.ListRacesResponse
+			if err := proto.Unmarshal(b, &got); err != nil {
+				t.Fatalf("response isn't binary protobuf: %s", err)
+			}
+
+			want := &racing.ListRacesResponse{Races: racingServer.races, Total: 2}
+			if !proto.Equal(&got, want) {
+				t.Errorf("response = %v, want %v", &got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// requestIDHeader is the header a request's ID is accepted from and returned in.
+	requestIDHeader = "X-Request-ID"
+
+	// requestIDMetadata is the request metadata the racing and sports servers log the ID of the
+	// request they serve under.
+	requestIDMetadata = "x-request-id"
+)
+
+// requestIDPattern matches the request IDs accepted from clients, which are otherwise replaced
+// so that they can't forge log lines.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDKey is the context key of the ID of the request being served.
+type requestIDKey struct{}
+
+// gatewayMetrics is a Prometheus collector of the requests served, counted by method and
+// status code and timed by method, and of the unary RPCs sent to serve them, likewise by their
+// full method name.
+type gatewayMetrics struct {
+	requests     *prometheus.CounterVec
+	durations    *prometheus.HistogramVec
+	rpcs         *prometheus.CounterVec
+	rpcDurations *prometheus.HistogramVec
+}
+
+// newGatewayMetrics creates an empty collector of the requests served.
+func newGatewayMetrics() *gatewayMetrics {
+	return &gatewayMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_http_requests_total",
+			Help: "Number of HTTP requests served, by method and status code.",
+		}, []string{"method", "code"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "api_http_request_duration_seconds",
+			Help:    "How long HTTP requests took to serve, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		rpcs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_grpc_client_requests_total",
+			Help: "Number of unary RPCs sent to the racing and sports servers, by method and status code.",
+		}, []string{"method", "code"}),
+		rpcDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "api_grpc_client_request_duration_seconds",
+			Help:    "How long unary RPCs sent to the racing and sports servers took, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *gatewayMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.durations.Describe(ch)
+	m.rpcs.Describe(ch)
+	m.rpcDurations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *gatewayMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.durations.Collect(ch)
+	m.rpcs.Collect(ch)
+	m.rpcDurations.Collect(ch)
+}
+
+// observeRequests logs each request as it ends, with its method, path, status, duration and
+// ID, and records it in the metrics unless they're nil. The ID is that sent in the
+// X-Request-ID header, or a new one when the client sent none, and is returned in the same
+// header and propagated to the RPCs sent to serve the request.
+func observeRequests(next http.Handler, gwMetrics *gatewayMetrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		elapsed := time.Since(start)
+
+		if gwMetrics != nil {
+			gwMetrics.requests.WithLabelValues(r.Method, strconv.Itoa(rec.status)).Inc()
+			gwMetrics.durations.WithLabelValues(r.Method).Observe(elapsed.Seconds())
+		}
+
+		log.Printf("http method=%s path=%q status=%d duration=%s request_id=%s\n", r.Method, r.URL.Path, rec.status, elapsed, id)
+	})
+}
+
+// statusRecorder records the status of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush sends everything written so far, so streamed responses aren't held back.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// propagateRequestID returns a client interceptor sending the ID of the request being served
+// with each unary RPC, and recording the RPC in the metrics unless they're nil.
+func propagateRequestID(gwMetrics *gatewayMetrics) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+
+		err := invoker(withRequestIDMetadata(ctx), method, req, reply, cc, opts...)
+
+		if gwMetrics != nil {
+			gwMetrics.rpcs.WithLabelValues(method, status.Code(err).String()).Inc()
+			gwMetrics.rpcDurations.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		}
+
+		return err
+	}
+}
+
+// propagateStreamRequestID returns a client interceptor sending the ID of the request being
+// served with each streaming RPC. Streams last as long as their client wants, so they aren't
+// timed.
+func propagateStreamRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withRequestIDMetadata(ctx), desc, cc, method, opts...)
+	}
+}
+
+// withRequestIDMetadata returns the context with the ID of the request being served added to
+// its outgoing metadata, if it has one.
+func withRequestIDMetadata(ctx context.Context) context.Context {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	if !ok {
+		return ctx
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, requestIDMetadata, id)
+}
+
+// newRequestID returns a random request ID, as 32 hex digits.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
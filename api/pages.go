@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"google.golang.org/protobuf/proto"
+)
+
+// listPageSize is how many races or events are listed by each request when listing every one,
+// being the most either backend lists at once.
+const listPageSize = 1000
+
+// listAllRaces lists every race matching the request, a page at a time, rather than only the
+// first page. The pages are of a racing snapshot, so races inserted while paging don't shift
+// those of later pages. The request's limit and page token are ignored, while its offset still
+// skips the races before it.
+func listAllRaces(ctx context.Context, racingClient racing.RacingClient, req *racing.ListRacesRequest) ([]*racing.Race, error) {
+	req = proto.Clone(req).(*racing.ListRacesRequest)
+	if req.Filter == nil {
+		req.Filter = &racing.ListRacesRequestFilter{}
+	}
+
+	req.Filter.Limit, req.PageToken, req.Snapshot = listPageSize, "", true
+
+	var races []*racing.Race
+
+	for {
+		resp, err := racingClient.ListRaces(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		races = append(races, resp.Races...)
+
+		if resp.NextPageToken == "" {
+			return races, nil
+		}
+
+		// Later pages continue the first's snapshot, with the statuses it derived as at.
+		req.Filter.SnapshotId, req.PageToken = resp.SnapshotId, resp.NextPageToken
+		if req.Filter.AsOf == nil {
+			req.Filter.AsOf = resp.AsOf
+		}
+	}
+}
+
+// listAllEvents lists every event matching the request, a page at a time, rather than only the
+// first page. The request's limit and page token are ignored, while its offset still skips the
+// events before it.
+func listAllEvents(ctx context.Context, sportsClient sports.SportsClient, req *sports.ListEventsRequest) ([]*sports.Event, error) {
+	req = proto.Clone(req).(*sports.ListEventsRequest)
+	if req.Filter == nil {
+		req.Filter = &sports.ListEventsRequestFilter{}
+	}
+
+	req.Filter.Limit, req.PageToken = listPageSize, ""
+
+	var events []*sports.Event
+
+	for {
+		resp, err := sportsClient.ListEvents(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, resp.Events...)
+
+		if resp.NextPageToken == "" {
+			return events, nil
+		}
+
+		req.PageToken = resp.NextPageToken
+	}
+}
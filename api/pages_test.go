@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+func TestListUpcomingRacesListsEveryPage(t *testing.T) {
+	const races = 2*listPageSize + 1
+
+	tests := []struct {
+		name     string
+		query    string
+		want     int
+		requests int
+	}{
+		{name: "every race", query: "", want: races, requests: 3},
+		{name: "requested limit", query: "?filter.limit=5", want: 5, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racingClient := &pagedRacingClient{}
+			for i := 1; i <= races; i++ {
+				racingClient.races = append(racingClient.races, &racing.Race{Id: int64(i)})
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/races.atom"+tt.query, nil)
+
+			listed, err := listUpcomingRaces(context.Background(), r, racingClient, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(listed) != tt.want || len(racingClient.requests) != tt.requests {
+				t.Errorf("listUpcomingRaces() = %d races in %d requests, want %d in %d", len(listed), len(racingClient.requests), tt.want, tt.requests)
+			}
+
+			for i, race := range listed {
+				if race.Id != int64(i+1) {
+					t.Fatalf("listUpcomingRaces() race %d = %d, want %d", i, race.Id, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestUpcomingLimitIsCapped(t *testing.T) {
+	mux := runtime.NewServeMux()
+	handler := newUpcomingHandler(mux, newJSONMarshaler(true), &pagedRacingClient{}, &pagedSportsClient{})
+
+	tests := []struct {
+		name  string
+		limit string
+		want  int
+	}{
+		{name: "maximum", limit: "1000", want: http.StatusOK},
+		{name: "beyond the maximum", limit: "1001", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/v1/upcoming?limit="+tt.limit, nil), nil)
+
+			if w.Code != tt.want {
+				t.Errorf("GET /v1/upcoming?limit=%s status = %d, want %d", tt.limit, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// serverTimeMetadata is the response header metadata in which the racing and sports servers
+// send the time list RPCs were handled at.
+const serverTimeMetadata = "server-time"
+
+// forwardServerTime returns a response modifier setting the header to the time the server
+// handled the RPC at, as RFC3339, for responses carrying one. Only list RPCs send it.
+func forwardServerTime(header string) func(context.Context, http.ResponseWriter, proto.Message) error {
+	return func(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+		md, ok := runtime.ServerMetadataFromContext(ctx)
+		if !ok {
+			return nil
+		}
+
+		if values := md.HeaderMD.Get(serverTimeMetadata); len(values) > 0 {
+			w.Header().Set(header, values[0])
+		}
+
+		return nil
+	}
+}
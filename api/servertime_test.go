@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// clockedRacingServer sends the time it handles list RPCs at, as the racing server does.
+type clockedRacingServer struct {
+	fakeRacingServer
+}
+
+func (c *clockedRacingServer) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(serverTimeMetadata, now)); err != nil {
+		return nil, err
+	}
+
+	return c.fakeRacingServer.ListRaces(ctx, in)
+}
+
+func TestForwardServerTime(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithForwardResponseOption(forwardServerTime("X-Server-Time")))
+
+	if err := racing.RegisterRacingHandlerServer(context.Background(), mux, &clockedRacingServer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("list races", func(t *testing.T) {
+		before := time.Now().Truncate(time.Second)
+
+		resp, err := http.Post(server.URL+"/v1/list-races", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		header := resp.Header.Get("X-Server-Time")
+		if header == "" {
+			t.Fatal("X-Server-Time is missing")
+		}
+
+		serverTime, err := time.Parse(time.RFC3339, header)
+		if err != nil {
+			t.Fatalf("X-Server-Time %q isn't RFC3339: %s", header, err)
+		}
+
+		if serverTime.Before(before) || serverTime.After(time.Now()) {
+			t.Errorf("X-Server-Time %s, want between %s and now", serverTime, before)
+		}
+	})
+
+	// Only list RPCs send the time.
+	t.Run("get race", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v1/races/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if header := resp.Header.Get("X-Server-Time"); header != "" {
+			t.Errorf("X-Server-Time %q, want none", header)
+		}
+	})
+}
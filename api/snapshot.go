@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// snapshot is the response of the snapshot endpoint. Each resource is marshalled by the JSON
+// marshaler, so resources are shaped as they are by every other endpoint.
+type snapshot struct {
+	AsOf   time.Time         `json:"asOf"`
+	Races  []json.RawMessage `json:"races"`
+	Events []json.RawMessage `json:"events"`
+	// Warnings describes the sections left empty because their backend failed.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// newSnapshotHandler returns the handler of GET /v1/snapshot, returning every race and sports
+// event with their statuses as at a single instant: the as_of query parameter (RFC3339) or
+// otherwise now. Racing failures fail the request, while the events are omitted with a warning
+// should the sports backend fail.
+func newSnapshotHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, racingClient racing.RacingClient, sportsClient sports.SportsClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx := r.Context()
+
+		// The instant is fixed here, so both backends evaluate statuses at the same time.
+		asOf := time.Now().UTC().Truncate(time.Second)
+
+		if value := r.URL.Query().Get("as_of"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid as_of %q", value))
+				return
+			}
+
+			asOf = parsed.UTC()
+		}
+
+		body, err := fetchSnapshot(ctx, marshaler, racingClient, sportsClient, asOf)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			log.Printf("failed writing snapshot: %s\n", err)
+		}
+	}
+}
+
+// fetchSnapshot lists every race and event as at asOf from both backends at once.
+func fetchSnapshot(ctx context.Context, marshaler runtime.Marshaler, racingClient racing.RacingClient, sportsClient sports.SportsClient, asOf time.Time) (*snapshot, error) {
+	ts, err := ptypes.TimestampProto(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg                  sync.WaitGroup
+		races               []*racing.Race
+		events              []*sports.Event
+		racesErr, eventsErr error
+	)
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		races, racesErr = listAllRaces(ctx, racingClient, &racing.ListRacesRequest{
+			Filter: &racing.ListRacesRequestFilter{AsOf: ts},
+		})
+	}()
+
+	go func() {
+		defer wg.Done()
+		events, eventsErr = listAllEvents(ctx, sportsClient, &sports.ListEventsRequest{
+			Filter: &sports.ListEventsRequestFilter{AsOf: ts},
+		})
+	}()
+
+	wg.Wait()
+
+	if racesErr != nil {
+		return nil, racesErr
+	}
+
+	body := &snapshot{AsOf: asOf, Races: []json.RawMessage{}, Events: []json.RawMessage{}}
+
+	for _, race := range races {
+		if body.Races, err = appendMarshalled(marshaler, body.Races, race); err != nil {
+			return nil, err
+		}
+	}
+
+	if eventsErr != nil {
+		log.Printf("failed listing events as at %s: %s\n", asOf.Format(time.RFC3339), eventsErr)
+		body.Warnings = append(body.Warnings, "sports events are unavailable: "+status.Convert(eventsErr).Message())
+
+		return body, nil
+	}
+
+	for _, event := range events {
+		if body.Events, err = appendMarshalled(marshaler, body.Events, event); err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}
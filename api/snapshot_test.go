@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// pagedRacingClient lists races a page at a time, as the racing server does.
+type pagedRacingClient struct {
+	racing.RacingClient
+	races    []*racing.Race
+	requests []*racing.ListRacesRequest
+}
+
+func (c *pagedRacingClient) ListRaces(_ context.Context, in *racing.ListRacesRequest, _ ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	c.requests = append(c.requests, proto.Clone(in).(*racing.ListRacesRequest))
+
+	races, next := page(len(c.races), in.Filter.GetLimit(), in.PageToken)
+
+	return &racing.ListRacesResponse{
+		Races:         c.races[races[0]:races[1]],
+		Total:         int64(len(c.races)),
+		NextPageToken: next,
+		SnapshotId:    int64(len(c.races)),
+	}, nil
+}
+
+// pagedSportsClient lists events a page at a time, as the sports server does.
+type pagedSportsClient struct {
+	sports.SportsClient
+	events   []*sports.Event
+	requests []*sports.ListEventsRequest
+}
+
+func (c *pagedSportsClient) ListEvents(_ context.Context, in *sports.ListEventsRequest, _ ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	c.requests = append(c.requests, proto.Clone(in).(*sports.ListEventsRequest))
+
+	events, next := page(len(c.events), in.Filter.GetLimit(), in.PageToken)
+
+	return &sports.ListEventsResponse{
+		Events:        c.events[events[0]:events[1]],
+		Total:         int64(len(c.events)),
+		NextPageToken: next,
+	}, nil
+}
+
+// page returns the bounds of the page of the results listed by the limit, of 100 by default,
+// following the token, and the token of the next page.
+func page(total int, limit int64, token string) ([2]int, string) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	start, _ := strconv.Atoi(token)
+
+	end := start + int(limit)
+	if end >= total {
+		return [2]int{start, total}, ""
+	}
+
+	return [2]int{start, end}, strconv.Itoa(end)
+}
+
+func TestFetchSnapshotListsEveryPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		races  int
+		events int
+	}{
+		{name: "empty", races: 0, events: 0},
+		{name: "one page", races: 5, events: 3},
+		{name: "several pages", races: 2*listPageSize + 1, events: listPageSize + 50},
+	}
+
+	asOf := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racingClient := &pagedRacingClient{}
+			for i := 1; i <= tt.races; i++ {
+				racingClient.races = append(racingClient.races, &racing.Race{Id: int64(i)})
+			}
+
+			sportsClient := &pagedSportsClient{}
+			for i := 1; i <= tt.events; i++ {
+				sportsClient.events = append(sportsClient.events, &sports.Event{Id: int64(i)})
+			}
+
+			body, err := fetchSnapshot(context.Background(), newJSONMarshaler(true), racingClient, sportsClient, asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(body.Races) != tt.races || len(body.Events) != tt.events {
+				t.Errorf("fetchSnapshot() = %d races and %d events, want %d and %d", len(body.Races), len(body.Events), tt.races, tt.events)
+			}
+
+			// Every page is of the same instant, and every page after the first of the same
+			// racing snapshot.
+			for i, req := range racingClient.requests {
+				if got, _ := ptypes.Timestamp(req.Filter.AsOf); !got.Equal(asOf) || !req.Snapshot {
+					t.Errorf("ListRaces() request %d as_of = %s, snapshot %t, want %s, snapshot", i, got, req.Snapshot, asOf)
+				}
+
+				if i > 0 && req.Filter.SnapshotId != int64(tt.races) {
+					t.Errorf("ListRaces() request %d snapshot_id = %d, want %d", i, req.Filter.SnapshotId, tt.races)
+				}
+			}
+
+			for i, req := range sportsClient.requests {
+				if got, _ := ptypes.Timestamp(req.Filter.AsOf); !got.Equal(asOf) {
+					t.Errorf("ListEvents() request %d as_of = %s, want %s", i, got, asOf)
+				}
+			}
+		})
+	}
+}
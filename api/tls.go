@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// dialCredentials returns the option dialling the racing and sports servers over TLS when
+// enabled, verifying them against the CA or otherwise the system's roots, or in plaintext when
+// not. Given a certificate and key, they're presented to the servers (mutual TLS).
+func dialCredentials(enabled bool, caFile, certFile, keyFile string) (grpc.DialOption, error) {
+	if !enabled {
+		if caFile != "" || certFile != "" || keyFile != "" {
+			return nil, errors.New("-grpc-tls-ca-file, -grpc-tls-cert-file and -grpc-tls-key-file require -grpc-tls")
+		}
+
+		return grpc.WithInsecure(), nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid -grpc-tls-ca-file %q: no PEM certificates", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gRPC client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
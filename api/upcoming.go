@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// upcomingRace and upcomingEvent are the types of upcoming items.
+	upcomingRace  = "race"
+	upcomingEvent = "event"
+
+	// defaultUpcomingLimit is the most items listed when no limit is asked for.
+	defaultUpcomingLimit = 100
+)
+
+// upcoming is the response of the upcoming endpoint.
+type upcoming struct {
+	Items []upcomingItem `json:"items"`
+	// Warnings describes the items left out because their backend failed.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// upcomingItem is a race or sports event, in the shape common to both. Only races and events
+// starting after a time are listed, so every item has a start time.
+type upcomingItem struct {
+	ID                  int64     `json:"id,string"`
+	Name                string    `json:"name"`
+	Type                string    `json:"type"`
+	AdvertisedStartTime time.Time `json:"advertisedStartTime"`
+	Status              string    `json:"status"`
+}
+
+// newUpcomingHandler returns the handler of GET /v1/upcoming, listing races and sports events
+// together, soonest first. Both are restricted to those starting after the start_time_after
+// query parameter (RFC3339) or otherwise now, before the start_time_before query parameter
+// when given, and to visible ones when visible_only is true. At most limit items are listed,
+// 100 by default and up to 1000. Racing failures fail the request, while the events are
+// omitted with a warning should the sports backend fail.
+//
+// Upcoming items are served by the gateway itself, like its feeds and snapshot, rather than
+// by a ListUpcoming RPC: neither backend holds the other's items, and the gateway has no gRPC
+// server of its own, so gRPC clients list from both backends themselves.
+func newUpcomingHandler(mux *runtime.ServeMux, marshaler runtime.Marshaler, racingClient racing.RacingClient, sportsClient sports.SportsClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		after := time.Now().UTC().Truncate(time.Second)
+		if value := query.Get("start_time_after"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid start_time_after %q", value))
+				return
+			}
+
+			after = parsed
+		}
+
+		var before *time.Time
+		if value := query.Get("start_time_before"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid start_time_before %q", value))
+				return
+			}
+
+			before = &parsed
+		}
+
+		var visibleOnly bool
+		if value := query.Get("visible_only"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid visible_only %q", value))
+				return
+			}
+
+			visibleOnly = parsed
+		}
+
+		limit := int64(defaultUpcomingLimit)
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed <= 0 {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "invalid limit %q", value))
+				return
+			}
+
+			// Each backend lists up to limit items in one page, so no more can be merged.
+			if parsed > listPageSize {
+				runtime.HTTPError(ctx, mux, marshaler, w, r, status.Errorf(codes.InvalidArgument, "limit %d exceeds the maximum of %d", parsed, listPageSize))
+				return
+			}
+
+			limit = parsed
+		}
+
+		body, err := fetchUpcoming(ctx, racingClient, sportsClient, after, before, visibleOnly, limit)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			log.Printf("failed writing upcoming items: %s\n", err)
+		}
+	}
+}
+
+// fetchUpcoming lists the soonest races and events from both backends at once, merging them
+// into up to limit items in start time order.
+func fetchUpcoming(ctx context.Context, racingClient racing.RacingClient, sportsClient sports.SportsClient, after time.Time, before *time.Time, visibleOnly bool, limit int64) (*upcoming, error) {
+	startAfter, err := ptypes.TimestampProto(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var startBefore *timestamp.Timestamp
+	if before != nil {
+		if startBefore, err = ptypes.TimestampProto(*before); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		wg                  sync.WaitGroup
+		races               *racing.ListRacesResponse
+		events              *sports.ListEventsResponse
+		racesErr, eventsErr error
+	)
+
+	wg.Add(2)
+
+	// Each backend lists its soonest items, as the merged items can't include any of the rest.
+	go func() {
+		defer wg.Done()
+		races, racesErr = racingClient.ListRaces(ctx, &racing.ListRacesRequest{
+			Filter: &racing.ListRacesRequestFilter{
+				VisibleOnly:     visibleOnly,
+				StartTimeAfter:  startAfter,
+				StartTimeBefore: startBefore,
+				Limit:           limit,
+			},
+		})
+	}()
+
+	go func() {
+		defer wg.Done()
+		events, eventsErr = sportsClient.ListEvents(ctx, &sports.ListEventsRequest{
+			Filter: &sports.ListEventsRequestFilter{
+				VisibleOnly:     visibleOnly,
+				StartTimeAfter:  startAfter,
+				StartTimeBefore: startBefore,
+				Limit:           limit,
+			},
+		})
+	}()
+
+	wg.Wait()
+
+	if racesErr != nil {
+		return nil, racesErr
+	}
+
+	body := &upcoming{Items: []upcomingItem{}}
+
+	for _, race := range races.Races {
+		body.Items = append(body.Items, upcomingItem{
+			ID:                  race.Id,
+			Name:                race.Name,
+			Type:                upcomingRace,
+			AdvertisedStartTime: race.AdvertisedStartTime.AsTime(),
+			Status:              race.Status.String(),
+		})
+	}
+
+	if eventsErr != nil {
+		log.Printf("failed listing upcoming events: %s\n", eventsErr)
+		body.Warnings = append(body.Warnings, "sports events are unavailable: "+status.Convert(eventsErr).Message())
+	}
+
+	for _, event := range events.GetEvents() {
+		body.Items = append(body.Items, upcomingItem{
+			ID:                  event.Id,
+			Name:                event.Name,
+			Type:                upcomingEvent,
+			AdvertisedStartTime: event.AdvertisedStartTime.AsTime(),
+			Status:              event.Status.String(),
+		})
+	}
+
+	// Items starting at the same time are ordered by type then ID, so the order is stable.
+	sort.SliceStable(body.Items, func(i, j int) bool {
+		a, b := body.Items[i], body.Items[j]
+		if !a.AdvertisedStartTime.Equal(b.AdvertisedStartTime) {
+			return a.AdvertisedStartTime.Before(b.AdvertisedStartTime)
+		}
+
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+
+		return a.ID < b.ID
+	})
+
+	if int64(len(body.Items)) > limit {
+		body.Items = body.Items[:limit]
+	}
+
+	return body, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unavailableSportsClient fails every call, as a sports backend that's down does.
+type unavailableSportsClient struct {
+	sports.SportsClient
+}
+
+func (unavailableSportsClient) ListEvents(context.Context, *sports.ListEventsRequest, ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	return nil, status.Error(codes.Unavailable, "sports is down")
+}
+
+func TestFetchUpcomingMergesInStartTimeOrder(t *testing.T) {
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	racingClient := &pagedRacingClient{}
+	for _, race := range []struct {
+		id      int64
+		minutes int
+	}{{1, 2}, {2, 1}} {
+		ts, _ := ptypes.TimestampProto(at(race.minutes))
+		racingClient.races = append(racingClient.races, &racing.Race{Id: race.id, AdvertisedStartTime: ts})
+	}
+
+	sportsClient := &pagedSportsClient{}
+	for _, event := range []struct {
+		id      int64
+		minutes int
+	}{{1, 1}, {2, 3}, {3, 0}} {
+		ts, _ := ptypes.TimestampProto(at(event.minutes))
+		sportsClient.events = append(sportsClient.events, &sports.Event{Id: event.id, AdvertisedStartTime: ts})
+	}
+
+	// item is an item's type and ID.
+	type item struct {
+		typ string
+		id  int64
+	}
+
+	tests := []struct {
+		name         string
+		sportsClient sports.SportsClient
+		limit        int64
+		want         []item
+		wantWarning  bool
+	}{
+		{
+			// Event 1 and race 2 start at the same time, so they're ordered by type.
+			name:         "merged",
+			sportsClient: sportsClient,
+			limit:        defaultUpcomingLimit,
+			want:         []item{{upcomingEvent, 3}, {upcomingEvent, 1}, {upcomingRace, 2}, {upcomingRace, 1}, {upcomingEvent, 2}},
+		},
+		{
+			name:         "limited",
+			sportsClient: sportsClient,
+			limit:        3,
+			want:         []item{{upcomingEvent, 3}, {upcomingEvent, 1}, {upcomingRace, 2}},
+		},
+		{
+			name:         "sports down",
+			sportsClient: unavailableSportsClient{},
+			limit:        defaultUpcomingLimit,
+			want:         []item{{upcomingRace, 2}, {upcomingRace, 1}},
+			wantWarning:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := fetchUpcoming(context.Background(), racingClient, tt.sportsClient, start, nil, false, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]item, 0, len(body.Items))
+			for _, upcoming := range body.Items {
+				got = append(got, item{upcoming.Type, upcoming.ID})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchUpcoming() items = %v, want %v", got, tt.want)
+			}
+
+			if gotWarning := len(body.Warnings) > 0; gotWarning != tt.wantWarning {
+				t.Errorf("fetchUpcoming() warnings = %q, want any %t", body.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
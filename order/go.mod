@@ -0,0 +1,3 @@
+module git.neds.sh/matty/entain/order
+
+go 1.16
@@ -0,0 +1,67 @@
+// Package order validates the orderings results are listed in, so that the racing and sports
+// services accept the same directions and reject bad orderings with the same errors.
+package order
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Asc is the direction listing results in ascending order.
+	Asc = "ASC"
+	// Desc is the direction listing results in descending order.
+	Desc = "DESC"
+)
+
+// Term is a field results are ordered by, in a direction as parsed by ParseDirection.
+type Term struct {
+	Field     string
+	Direction string
+}
+
+// ParseDirection returns the SQL direction named by s, ignoring case, or Asc when s is empty.
+func ParseDirection(s string) (string, error) {
+	switch strings.ToUpper(s) {
+	case "", Asc:
+		return Asc, nil
+	case Desc:
+		return Desc, nil
+	default:
+		return "", fmt.Errorf("unknown direction %q: must be %s or %s", s, Asc, Desc)
+	}
+}
+
+// ValidateOrderTerms returns an error if there are more than max terms, when max is positive,
+// or any term orders by a field not among fields, in an unknown direction, or by the same field
+// as an earlier term.
+func ValidateOrderTerms(terms []Term, fields []string, max int) error {
+	if max > 0 && len(terms) > max {
+		return fmt.Errorf("%d fields given, but results may be ordered by at most %d", len(terms), max)
+	}
+
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[field] = true
+	}
+
+	seen := make(map[string]bool, len(terms))
+
+	for _, term := range terms {
+		if !allowed[term.Field] {
+			return fmt.Errorf("unknown field %q: must be one of %s", term.Field, strings.Join(fields, ", "))
+		}
+
+		if _, err := ParseDirection(term.Direction); err != nil {
+			return err
+		}
+
+		if seen[term.Field] {
+			return fmt.Errorf("results are ordered by %s more than once", term.Field)
+		}
+
+		seen[term.Field] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package order
+
+import "testing"
+
+func TestParseDirection(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: Asc},
+		{in: "ASC", want: Asc},
+		{in: "DESC", want: Desc},
+		{in: "asc", want: Asc},
+		{in: "desc", want: Desc},
+		{in: "Desc", want: Desc},
+		{in: "descending", wantErr: true},
+		{in: " ASC", wantErr: true},
+		{in: "ASC; DROP TABLE races", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDirection(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDirection(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseDirection(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOrderTerms(t *testing.T) {
+	fields := []string{"START_TIME", "NAME"}
+
+	tests := []struct {
+		name    string
+		terms   []Term
+		max     int
+		wantErr bool
+	}{
+		{name: "none", terms: nil},
+		{name: "valid", terms: []Term{{Field: "NAME", Direction: "DESC"}, {Field: "START_TIME"}}},
+		{name: "lowercase direction", terms: []Term{{Field: "NAME", Direction: "desc"}}},
+		{name: "within max", terms: []Term{{Field: "NAME"}, {Field: "START_TIME"}}, max: 2},
+		{name: "beyond max", terms: []Term{{Field: "NAME"}, {Field: "START_TIME"}}, max: 1, wantErr: true},
+		{name: "unknown field", terms: []Term{{Field: "MEETING"}}, wantErr: true},
+		{name: "lowercase field", terms: []Term{{Field: "name"}}, wantErr: true},
+		{name: "unknown direction", terms: []Term{{Field: "NAME", Direction: "UP"}}, wantErr: true},
+		{name: "repeated field", terms: []Term{{Field: "NAME"}, {Field: "NAME", Direction: "DESC"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateOrderTerms(tt.terms, fields, tt.max); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOrderTerms() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"google.golang.org/protobuf/proto"
+)
+
+// raceCache holds races fetched by ID for a fixed time to live, sparing the database repeated
+// fetches of the same race.
+//
+// A nil raceCache caches nothing.
+type raceCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[int64]cachedRace
+	// generations count the invalidations of each race, and epoch those of every race, so races
+	// fetched before an invalidation aren't cached after it.
+	generations map[int64]uint64
+	epoch       uint64
+}
+
+// cacheGeneration identifies the invalidations a race has had when it's fetched. Only races
+// fetched since their latest invalidation are cached.
+type cacheGeneration struct {
+	race, epoch uint64
+}
+
+type cachedRace struct {
+	race    *racing.Race
+	expires time.Time
+}
+
+// newRaceCache creates a cache holding races for ttl. A ttl of zero or less returns a nil,
+// disabled, cache.
+func newRaceCache(ttl time.Duration) *raceCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &raceCache{ttl: ttl, entries: make(map[int64]cachedRace), generations: make(map[int64]uint64)}
+}
+
+// get returns a copy of the cached race with the given ID, or nil if it isn't cached or has
+// expired.
+func (c *raceCache) get(id int64) *racing.Race {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		return nil
+	}
+
+	return proto.Clone(entry.race).(*racing.Race)
+}
+
+// generation returns the generation of the race with the given ID, to be taken before it's
+// fetched from the database.
+func (c *raceCache) generation(id int64) cacheGeneration {
+	if c == nil {
+		return cacheGeneration{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return cacheGeneration{race: c.generations[id], epoch: c.epoch}
+}
+
+// put caches a copy of the race, so later changes to it aren't reflected in the cache, unless
+// it's been invalidated since the generation it was fetched at. The race may have been written
+// to since it was fetched, so caching it would serve the race as it was before the write.
+func (c *raceCache) put(race *racing.Race, fetched cacheGeneration) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if fetched != (cacheGeneration{race: c.generations[race.Id], epoch: c.epoch}) {
+		return
+	}
+
+	c.entries[race.Id] = cachedRace{
+		race:    proto.Clone(race).(*racing.Race),
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+// invalidate drops the races with the given IDs from the cache.
+func (c *raceCache) invalidate(ids ...int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		delete(c.entries, id)
+		c.generations[id]++
+	}
+}
+
+// clear drops every race from the cache.
+func (c *raceCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[int64]cachedRace)
+	c.generations = make(map[int64]uint64)
+	c.epoch++
+}
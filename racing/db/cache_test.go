@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// spyConnector connects to a database through the repository's driver, counting the statements
+// prepared on its connections.
+type spyConnector struct {
+	driver   driver.Driver
+	name     string
+	prepared int64
+}
+
+func (c *spyConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spyConn{Conn: conn, prepared: &c.prepared}, nil
+}
+
+func (c *spyConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// spyConn counts the statements prepared on it. It hides the optional interfaces of the
+// connection it wraps, so every query is prepared.
+type spyConn struct {
+	driver.Conn
+	prepared *int64
+}
+
+func (c *spyConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(c.prepared, 1)
+	return c.Conn.Prepare(query)
+}
+
+// queries returns how many statements have been prepared through the connector.
+func (c *spyConnector) queries() int64 {
+	return atomic.LoadInt64(&c.prepared)
+}
+
+// newSpyRepo returns a races repository caching races for ttl, holding just the given races,
+// over a database connected to through the returned spy.
+func newSpyRepo(t *testing.T, ttl time.Duration, races ...*racing.Race) (RacesRepo, *spyConnector) {
+	t.Helper()
+
+	racingDB, err := sql.Open(DriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spy := &spyConnector{driver: racingDB.Driver(), name: filepath.Join(t.TempDir(), "racing.db")}
+
+	spyDB := sql.OpenDB(spy)
+	t.Cleanup(func() { spyDB.Close() })
+
+	return initTestRepo(t, spyDB, races, WithRaceCache(ttl)), spy
+}
+
+func TestGetServedFromCache(t *testing.T) {
+	repo, spy := newSpyRepo(t, time.Hour, dbtest.NewRace(t, 1, 1, 1, time.Now().Add(time.Hour)), dbtest.NewRace(t, 2, 1, 2, time.Now().Add(time.Hour)))
+
+	get := func(id int64) (*racing.Race, int64) {
+		t.Helper()
+
+		before := spy.queries()
+
+		race, err := repo.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return race, spy.queries() - before
+	}
+
+	if _, queries := get(1); queries == 0 {
+		t.Fatal("first Get(1) didn't query the database")
+	}
+
+	if race, queries := get(1); queries != 0 || race.Name != "Race 1" {
+		t.Errorf("second Get(1) = %q with %d queries, want %q from the cache", race.Name, queries, "Race 1")
+	}
+
+	if _, err := repo.Update(context.Background(), &racing.Race{Id: 1, Name: "Renamed"}, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if race, queries := get(1); queries == 0 || race.Name != "Renamed" {
+		t.Errorf("Get(1) after Update() = %q with %d queries, want %q from the database", race.Name, queries, "Renamed")
+	}
+
+	if race, queries := get(1); queries != 0 || race.Name != "Renamed" {
+		t.Errorf("second Get(1) after Update() = %q with %d queries, want %q from the cache", race.Name, queries, "Renamed")
+	}
+
+	// Other races stay cached while one is written to.
+	get(2)
+
+	if _, err := repo.Update(context.Background(), &racing.Race{Id: 1, Name: "Renamed again"}, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, queries := get(2); queries != 0 {
+		t.Errorf("Get(2) after Update() of race 1 made %d queries, want it served from the cache", queries)
+	}
+
+	if _, err := repo.Cancel(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if race, queries := get(2); queries == 0 || race.Status != racing.RaceStatus_CANCELLED {
+		t.Errorf("Get(2) after Cancel() = %v with %d queries, want %v from the database", race.Status, queries, racing.RaceStatus_CANCELLED)
+	}
+}
+
+func TestGetFromCacheDerivesStatusAsAtNow(t *testing.T) {
+	repo, spy := newSpyRepo(t, time.Hour)
+
+	// The race is added once the repository is set up, however long that takes, so it's still
+	// to start when first fetched. Start times are stored to the second.
+	start := time.Now().Truncate(time.Second).Add(2 * time.Second)
+	if err := repo.InsertBatch(context.Background(), []*racing.Race{dbtest.NewRace(t, 1, 1, 1, start)}); err != nil {
+		t.Fatal(err)
+	}
+
+	race, err := repo.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if race.Status != racing.RaceStatus_OPEN || !race.BettingOpen {
+		t.Fatalf("Get(1) before the start = %v, betting open %t, want %v, betting open", race.Status, race.BettingOpen, racing.RaceStatus_OPEN)
+	}
+
+	time.Sleep(time.Until(start.Add(100 * time.Millisecond)))
+	before := spy.queries()
+
+	race, err = repo.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spy.queries() != before {
+		t.Error("Get(1) after the start wasn't served from the cache")
+	}
+
+	if race.Status != racing.RaceStatus_CLOSED || race.BettingOpen {
+		t.Errorf("Get(1) after the start = %v, betting open %t, want %v, betting closed", race.Status, race.BettingOpen, racing.RaceStatus_CLOSED)
+	}
+}
+
+func TestCacheDropsRacesFetchedBeforeInvalidation(t *testing.T) {
+	race := dbtest.NewRace(t, 1, 1, 1, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name string
+		// write is made between the race being fetched and cached, as by an Update or Cancel
+		// racing a Get.
+		write      func(c *raceCache)
+		wantCached bool
+	}{
+		{name: "no write", write: func(*raceCache) {}, wantCached: true},
+		{name: "race invalidated", write: func(c *raceCache) { c.invalidate(1) }},
+		{name: "other race invalidated", write: func(c *raceCache) { c.invalidate(2) }, wantCached: true},
+		{name: "cache cleared", write: func(c *raceCache) { c.clear() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRaceCache(time.Hour)
+
+			fetched := c.generation(1)
+			tt.write(c)
+			c.put(race, fetched)
+
+			if cached := c.get(1) != nil; cached != tt.wantCached {
+				t.Errorf("cached = %t, want %t", cached, tt.wantCached)
+			}
+
+			// Fetching the race again, after the write, caches it.
+			c.put(race, c.generation(1))
+
+			if c.get(1) == nil {
+				t.Error("race fetched after the write wasn't cached")
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package db
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/mattn/go-sqlite3"
 	"syreclabs.com/go/faker"
 )
 
@@ -26,5 +28,148 @@ func (r *racesRepo) seed() error {
 		}
 	}
 
+	statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS meetings (id INTEGER PRIMARY KEY, name TEXT, visible INTEGER)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	for i := 1; i <= 10; i++ {
+		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO meetings(id, name, visible) VALUES (?,?,?)`)
+		if err == nil {
+			_, err = statement.Exec(
+				i,
+				faker.Address().City(),
+				faker.Number().Between(0, 1),
+			)
+		}
+	}
+
 	return err
 }
+
+// migrate brings the schema of an existing database up to date.
+func (r *racesRepo) migrate() error {
+	if err := r.addColumn("races", "cancelled", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Soft purged races are kept, but otherwise treated as if they were deleted.
+	if err := r.addColumn("races", "purged", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Races added before updates were tracked, or never updated since, have no update time.
+	if err := r.addColumn("races", "updated_at", "TEXT"); err != nil {
+		return err
+	}
+
+	if err := r.addColumn("races", "is_feature", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(getRaceQueries()[racesSeedFeatures]); err != nil {
+		return err
+	}
+
+	if err := r.addColumn("meetings", "timezone", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(getRaceQueries()[racesSeedTimezones]); err != nil {
+		return err
+	}
+
+	// Each race has at most one result, of a runner in each position.
+	if _, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS race_results (race_id INTEGER NOT NULL, position INTEGER NOT NULL, runner_number INTEGER NOT NULL, runner_name TEXT NOT NULL, recorded_at TEXT NOT NULL, PRIMARY KEY (race_id, position))`); err != nil {
+		return err
+	}
+
+	// Races are filtered by the start time as parsed, so that's what's indexed.
+	if _, err := r.db.Exec(`CREATE INDEX IF NOT EXISTS races_visible_start ON races (visible, datetime(advertised_start_time))`); err != nil {
+		return err
+	}
+
+	// LIKE ignores case, so only case-insensitive indexes serve name prefix matches.
+	if _, err := r.db.Exec(`CREATE INDEX IF NOT EXISTS races_name ON races (name COLLATE NOCASE)`); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`CREATE INDEX IF NOT EXISTS meetings_name ON meetings (name COLLATE NOCASE)`)
+
+	return err
+}
+
+// addColumn adds the column to the table, unless it already exists.
+func (r *racesRepo) addColumn(table, column, definition string) error {
+	rows, err := r.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+
+	return err
+}
+
+// parseStartTime parses the stored start time of the race. Strict repositories only accept
+// RFC3339, while others also accept the formats the SQLite driver parses, as UTC.
+func (r *racesRepo) parseStartTime(id int64, value string) (time.Time, error) {
+	if start, err := time.Parse(time.RFC3339, value); err == nil {
+		return start, nil
+	}
+
+	if r.strict {
+		return time.Time{}, fmt.Errorf("race %d has a start time that isn't RFC3339: %q", id, value)
+	}
+
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if start, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return start, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("race %d has a start time that can't be parsed: %q", id, value)
+}
+
+// checkStartTimes returns an error naming the first race with a start time that isn't RFC3339.
+func (r *racesRepo) checkStartTimes() error {
+	rows, err := r.db.Query(getRaceQueries()[racesStartTimes])
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id    int64
+			start string
+		)
+
+		if err := rows.Scan(&id, &start); err != nil {
+			return err
+		}
+
+		if _, err := r.parseStartTime(id, start); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
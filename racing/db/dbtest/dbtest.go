@@ -0,0 +1,88 @@
+// Package dbtest provides the fixtures shared by tests of the racing database. It doesn't
+// depend on the db package, so the db package's own tests can use it too.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// Repo is the part of a races repository fixtures are loaded through.
+type Repo interface {
+	Init() error
+	InsertBatch(ctx context.Context, races []*racing.Race) error
+}
+
+// Open returns a database with the driver over a new file in a temporary directory, closed
+// once the test finishes.
+func Open(tb testing.TB, driverName string) *sql.DB {
+	tb.Helper()
+
+	racingDB, err := sql.Open(driverName, filepath.Join(tb.TempDir(), "racing.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { racingDB.Close() })
+
+	return racingDB
+}
+
+// Load initialises the repository over the database, migrating its schema and seeding
+// meetings, then replaces the seeded races with just the given races.
+func Load(tb testing.TB, racingDB *sql.DB, repo Repo, races ...*racing.Race) {
+	tb.Helper()
+
+	if err := repo.Init(); err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err := racingDB.Exec(`DELETE FROM races`); err != nil {
+		tb.Fatal(err)
+	}
+
+	if len(races) > 0 {
+		if err := repo.InsertBatch(context.Background(), races); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// NewRace returns a visible race of the meeting named after its ID, starting at the given time.
+func NewRace(tb testing.TB, id, meetingID, number int64, start time.Time) *racing.Race {
+	tb.Helper()
+
+	advertisedStart, err := ptypes.TimestampProto(start)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return &racing.Race{
+		Id:                  id,
+		MeetingId:           meetingID,
+		Name:                fmt.Sprintf("Race %d", id),
+		Number:              number,
+		Visible:             true,
+		AdvertisedStartTime: advertisedStart,
+	}
+}
+
+// NewRaces returns visible races of meeting 1 starting at the given times, numbered in turn
+// from 1, with their numbers as IDs.
+func NewRaces(tb testing.TB, starts ...time.Time) []*racing.Race {
+	tb.Helper()
+
+	races := make([]*racing.Race, len(starts))
+	for i, start := range starts {
+		races[i] = NewRace(tb, int64(i+1), 1, int64(i+1), start)
+	}
+
+	return races
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// holidayLayout is the layout of holiday dates.
+const holidayLayout = "2006-01-02"
+
+// LoadHolidays reads the holiday dates listed in the file at path, one YYYY-MM-DD date per
+// line. Blank lines and lines starting with # are ignored.
+func LoadHolidays(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		holidays []string
+		scanner  = bufio.NewScanner(file)
+	)
+
+	for line := 1; scanner.Scan(); line++ {
+		date := strings.TrimSpace(scanner.Text())
+		if date == "" || strings.HasPrefix(date, "#") {
+			continue
+		}
+
+		if _, err := time.Parse(holidayLayout, date); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid holiday date %q", path, line, date)
+		}
+
+		holidays = append(holidays, date)
+	}
+
+	return holidays, scanner.Err()
+}
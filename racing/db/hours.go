@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockLayout is the layout of the clock times business hours start and end at.
+const clockLayout = "15:04"
+
+const (
+	// defaultBusinessHoursStart and defaultBusinessHoursEnd are the business hours when none are
+	// configured.
+	defaultBusinessHoursStart = "09:00"
+	defaultBusinessHoursEnd   = "17:00"
+
+	// offsetChangeYears is how many years either side of now the offset changes of a timezone
+	// are looked for, such as those of daylight saving time. Outside them, the offsets at either
+	// end apply.
+	offsetChangeYears = 10
+)
+
+// ParseBusinessHours parses business hours given as the HH:MM clock times they start and end
+// at, such as 09:00-17:00. Hours ending earlier than they start span midnight.
+func ParseBusinessHours(hours string) (string, string, error) {
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid business hours %q: must be HH:MM-HH:MM", hours)
+	}
+
+	for _, part := range parts {
+		if _, err := time.Parse(clockLayout, part); err != nil {
+			return "", "", fmt.Errorf("invalid business hours %q: must be HH:MM-HH:MM", hours)
+		}
+	}
+
+	if parts[0] == parts[1] {
+		return "", "", fmt.Errorf("invalid business hours %q: must end at a different time than they start", hours)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// businessHoursClause returns the clause matching races starting within business hours on the
+// clock of the location, from their start up to but excluding their end, along with its args.
+func (r *racesRepo) businessHoursClause(location *time.Location, now time.Time) (string, []interface{}) {
+	start, end := r.businessHoursStart, r.businessHoursEnd
+	if start == "" {
+		start, end = defaultBusinessHoursStart, defaultBusinessHoursEnd
+	}
+
+	clock, args := clockExpression(location, now.AddDate(-offsetChangeYears, 0, 0), now.AddDate(offsetChangeYears, 0, 0))
+
+	// Clock times are to the minute, so the minute before a time is the last one preceding it.
+	if start < end {
+		return clock + " BETWEEN ? AND ?", append(args, start, minuteBefore(end))
+	}
+
+	return clock + " NOT BETWEEN ? AND ?", append(args, end, minuteBefore(start))
+}
+
+// clockExpression returns the SQL expression of the HH:MM clock time races start at in the
+// location, along with its args. SQLite only shifts times by a fixed offset, so the offset
+// applied is chosen by comparing the start time with each change of offset between from and
+// to.
+func clockExpression(location *time.Location, from, to time.Time) (string, []interface{}) {
+	var (
+		whens []string
+		args  []interface{}
+	)
+
+	_, offset := from.In(location).Zone()
+
+	// Offsets change at most once a day, so only days spanning a change are searched for it.
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+
+		_, nextOffset := next.In(location).Zone()
+		if nextOffset == offset {
+			continue
+		}
+
+		whens = append(whens, "WHEN datetime(races.advertised_start_time) < datetime(?) THEN ?")
+		args = append(args, offsetChange(location, day, next).Format(time.RFC3339), offsetModifier(offset))
+
+		offset = nextOffset
+	}
+
+	if len(whens) == 0 {
+		return "strftime('%H:%M', races.advertised_start_time, ?)", []interface{}{offsetModifier(offset)}
+	}
+
+	return "strftime('%H:%M', races.advertised_start_time, CASE " + strings.Join(whens, " ") + " ELSE ? END)", append(args, offsetModifier(offset))
+}
+
+// offsetChange returns the instant the offset of the location changes, between from and to,
+// given that it does.
+func offsetChange(location *time.Location, from, to time.Time) time.Time {
+	_, offset := from.In(location).Zone()
+
+	for to.Sub(from) > time.Second {
+		middle := from.Add(to.Sub(from) / 2)
+
+		if _, middleOffset := middle.In(location).Zone(); middleOffset == offset {
+			from = middle
+		} else {
+			to = middle
+		}
+	}
+
+	return to.Truncate(time.Second)
+}
+
+// offsetModifier returns the SQLite date modifier shifting a UTC time by offset seconds.
+func offsetModifier(offset int) string {
+	return strconv.Itoa(offset) + " seconds"
+}
+
+// minuteBefore returns the HH:MM clock time a minute before the given one.
+func minuteBefore(clock string) string {
+	t, _ := time.Parse(clockLayout, clock)
+
+	return t.Add(-time.Minute).Format(clockLayout)
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTooManyQueries is returned when a query is rejected because the limit of concurrent
+// queries has been reached.
+var ErrTooManyQueries = errors.New("too many concurrent queries")
+
+// QueryLimiter bounds the number of queries a repository runs against the database at once.
+// Queries beyond the limit either queue until a slot frees up or their context is done, or
+// are rejected outright.
+//
+// A nil QueryLimiter places no bound on queries.
+type QueryLimiter struct {
+	slots  chan struct{}
+	reject bool
+}
+
+// NewQueryLimiter creates a limiter allowing up to max concurrent queries, rejecting any
+// excess queries with ErrTooManyQueries when reject is set. A max of zero or less returns a
+// nil, unbounded, limiter.
+func NewQueryLimiter(max int, reject bool) *QueryLimiter {
+	if max <= 0 {
+		return nil
+	}
+
+	return &QueryLimiter{
+		slots:  make(chan struct{}, max),
+		reject: reject,
+	}
+}
+
+// acquire claims a query slot, which must then be released once the query completes. A
+// queued query gives up with the context's error once the context is done.
+func (l *QueryLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	if !l.reject {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return ErrTooManyQueries
+	}
+}
+
+// release frees a slot claimed by acquire.
+func (l *QueryLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	<-l.slots
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func TestQueryLimiter(t *testing.T) {
+	const (
+		limit   = 2
+		queries = 5
+	)
+
+	tests := []struct {
+		name   string
+		reject bool
+	}{
+		{name: "queue", reject: false},
+		{name: "reject", reject: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewQueryLimiter(limit, tt.reject)
+
+			_, racingDB := newTestRepo(t, []*racing.Race{dbtest.NewRace(t, 1, 1, 1, time.Now())})
+			repo := NewRacesRepo(racingDB, limiter)
+
+			// Other queries hold every slot while the repository's queries are fired.
+			for i := 0; i < limit; i++ {
+				if err := limiter.acquire(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var (
+				wg   sync.WaitGroup
+				errs = make(chan error, queries)
+			)
+
+			for i := 0; i < queries; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := repo.Get(context.Background(), 1)
+					errs <- err
+				}()
+			}
+
+			if tt.reject {
+				wg.Wait()
+			} else {
+				select {
+				case err := <-errs:
+					t.Fatalf("query completed with every slot held, with error %v", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+
+			for i := 0; i < limit; i++ {
+				limiter.release()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if tt.reject && !errors.Is(err, ErrTooManyQueries) {
+					t.Errorf("query beyond the limit error = %v, want %v", err, ErrTooManyQueries)
+				}
+
+				if !tt.reject && err != nil {
+					t.Errorf("queued query error = %v, want none", err)
+				}
+			}
+
+			// Every slot is free again once the queries complete.
+			for i := 0; i < limit; i++ {
+				if err := limiter.acquire(context.Background()); err != nil {
+					t.Fatalf("acquire() after queries completed error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestQueuedQueryGivesUpWithContext(t *testing.T) {
+	limiter := NewQueryLimiter(1, false)
+
+	_, racingDB := newTestRepo(t, []*racing.Race{dbtest.NewRace(t, 1, 1, 1, time.Now())})
+	repo := NewRacesRepo(racingDB, limiter)
+
+	// Another query holds the only slot for the whole test.
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer limiter.release()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timedOut, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{name: "cancelled", ctx: cancelled, want: context.Canceled},
+		{name: "timed out", ctx: timedOut, want: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				_, err := repo.Get(tt.ctx, 1)
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.want) {
+					t.Errorf("queued query error = %v, want %v", err, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("queued query still waiting for a slot after its context was done")
+			}
+		})
+	}
+}
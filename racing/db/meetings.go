@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// sqliteDateTime is the layout of timestamps returned by SQLite's datetime function, in UTC.
+const sqliteDateTime = "2006-01-02 15:04:05"
+
+// MeetingsRepo provides repository access to meetings.
+type MeetingsRepo interface {
+	// List will return a list of all meetings, summarising their races.
+	List(ctx context.Context) ([]*racing.Meeting, error)
+}
+
+type meetingsRepo struct {
+	db      *sql.DB
+	limiter *QueryLimiter
+}
+
+// NewMeetingsRepo creates a new meetings repository, bounding its concurrent queries by the
+// given limiter. Meetings are seeded by the races repository.
+func NewMeetingsRepo(db *sql.DB, limiter *QueryLimiter) MeetingsRepo {
+	return &meetingsRepo{db: db, limiter: limiter}
+}
+
+// List returns every meeting, with its races summarised as at now.
+func (m *meetingsRepo) List(ctx context.Context) ([]*racing.Meeting, error) {
+	if err := m.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.limiter.release()
+
+	now := time.Now().Format(time.RFC3339)
+
+	rows, err := m.db.QueryContext(ctx, getMeetingQueries()[meetingsList], now, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var meetings []*racing.Meeting
+
+	for rows.Next() {
+		var meeting racing.Meeting
+		var nextRaceStart, firstRaceStart, lastRaceStart sql.NullString
+
+		if err := rows.Scan(&meeting.Id, &meeting.Name, &meeting.Visible, &meeting.Timezone, &meeting.OpenRaceCount, &meeting.ClosedRaceCount, &nextRaceStart, &firstRaceStart, &lastRaceStart); err != nil {
+			return nil, err
+		}
+
+		if meeting.NextRaceStartTime, err = parseStartTime(nextRaceStart); err != nil {
+			return nil, err
+		}
+
+		if meeting.FirstRaceStartTime, err = parseStartTime(firstRaceStart); err != nil {
+			return nil, err
+		}
+
+		if meeting.LastRaceStartTime, err = parseStartTime(lastRaceStart); err != nil {
+			return nil, err
+		}
+
+		meetings = append(meetings, &meeting)
+	}
+
+	return meetings, rows.Err()
+}
+
+// parseStartTime parses a start time returned by SQLite's datetime function, returning nil
+// when there's no such time.
+func parseStartTime(value sql.NullString) (*timestamp.Timestamp, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	start, err := time.Parse(sqliteDateTime, value.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return ptypes.TimestampProto(start)
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// startTimeEqual reports whether the start time is the given time, or is unset for the zero time.
+func startTimeEqual(startTime *timestamp.Timestamp, want time.Time) bool {
+	if want.IsZero() {
+		return startTime == nil
+	}
+
+	return startTime != nil && startTime.AsTime().Equal(want)
+}
+
+func TestListMeetingsSummarisesRaces(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	races, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(30*time.Minute)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Hour)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(time.Hour+30*time.Minute)),
+		dbtest.NewRace(t, 6, 1, 5, now.Add(2*time.Hour)),
+		dbtest.NewRace(t, 5, 2, 1, now.Add(-2*time.Hour)),
+	})
+
+	// Cancelled races are neither open nor closed, so race 3 is the next of meeting 1, though
+	// race 6 is still its last.
+	for _, id := range []int64{2, 6} {
+		if _, err := races.Cancel(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	meetings, err := NewMeetingsRepo(racingDB, nil).List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(map[int64]*racing.Meeting, len(meetings))
+	for _, meeting := range meetings {
+		listed[meeting.Id] = meeting
+	}
+
+	tests := []struct {
+		name      string
+		id        int64
+		open      int64
+		closed    int64
+		nextStart time.Time
+		first     time.Time
+		last      time.Time
+	}{
+		{name: "open and closed races", id: 1, open: 2, closed: 1, nextStart: now.Add(time.Hour), first: now.Add(-time.Hour), last: now.Add(2 * time.Hour)},
+		{name: "only closed races", id: 2, open: 0, closed: 1, first: now.Add(-2 * time.Hour), last: now.Add(-2 * time.Hour)},
+		{name: "no races", id: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meeting, ok := listed[tt.id]
+			if !ok {
+				t.Fatalf("List() is missing meeting %d", tt.id)
+			}
+
+			if meeting.OpenRaceCount != tt.open || meeting.ClosedRaceCount != tt.closed {
+				t.Errorf("meeting %d counts = %d open, %d closed, want %d and %d", tt.id, meeting.OpenRaceCount, meeting.ClosedRaceCount, tt.open, tt.closed)
+			}
+
+			if !startTimeEqual(meeting.NextRaceStartTime, tt.nextStart) {
+				t.Errorf("meeting %d next start = %v, want %s", tt.id, meeting.NextRaceStartTime, tt.nextStart)
+			}
+			if !startTimeEqual(meeting.FirstRaceStartTime, tt.first) || !startTimeEqual(meeting.LastRaceStartTime, tt.last) {
+				t.Errorf("meeting %d first and last starts = %v and %v, want %s and %s", tt.id, meeting.FirstRaceStartTime, meeting.LastRaceStartTime, tt.first, tt.last)
+			}
+		})
+	}
+}
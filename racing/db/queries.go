@@ -1,20 +1,456 @@
 package db
 
+import (
+	"fmt"
+	"strconv"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
 const (
-	racesList = "list"
+	racesList                    = "list"
+	racesFirstPerMeeting         = "firstPerMeeting"
+	racesLastPerMeeting          = "lastPerMeeting"
+	racesPerMeetingLimit         = "perMeetingLimit"
+	racesNext                    = "next"
+	racesRank                    = "rank"
+	racesMeetingCounts           = "meetingCounts"
+	racesInsert                  = "insert"
+	racesMeetingExists           = "meeting_exists"
+	racesIDs                     = "ids"
+	racesCount                   = "count"
+	racesNumbers                 = "numbers"
+	racesCancel                  = "cancel"
+	racesUpdate                  = "update"
+	racesStatusSummary           = "statusSummary"
+	racesVisibilitySummary       = "visibilitySummary"
+	racesStartClashes            = "startClashes"
+	racesDataQuality             = "dataQuality"
+	racesStartTimes              = "startTimes"
+	racesTimeline                = "timeline"
+	racesPurgeCount              = "purgeCount"
+	racesPurge                   = "purge"
+	racesSoftPurge               = "softPurge"
+	racesSeedFeatures            = "seedFeatures"
+	racesSeedTimezones           = "seedTimezones"
+	racesMaxID                   = "maxID"
+	racesStartSeconds            = "startSeconds"
+	racesUncancelledStartSeconds = "uncancelledStartSeconds"
+	racesHasRun                  = "hasRun"
+	racesDeleteResult            = "deleteResult"
+	racesInsertPlacing           = "insertPlacing"
+	racesResult                  = "result"
+	racesPurgeResults            = "purgeResults"
 )
 
 func getRaceQueries() map[string]string {
 	return map[string]string{
+		// The placeholders of the status and betting open columns are bound to the instant
+		// statuses are evaluated at, and that instant plus the betting cutoff, respectively.
 		racesList: `
+			SELECT 
+				races.id AS id, 
+				races.meeting_id AS meeting_id, 
+				races.name AS name, 
+				races.number AS number, 
+				races.visible AS visible, 
+				CAST(races.advertised_start_time AS TEXT) AS advertised_start_time, 
+				` + raceStatusExpression + ` AS status, 
+				` + bettingOpenExpression + ` AS betting_open, 
+				0 AS rank, 
+				COALESCE(meetings.timezone, '') AS meeting_timezone, 
+				0 AS meeting_open_race_count 
+			FROM races
+			LEFT JOIN meetings ON meetings.id = races.meeting_id
+		`,
+		// Wraps a (filtered) races query, keeping only the lowest numbered race per meeting.
+		racesFirstPerMeeting: `
 			SELECT 
 				id, 
 				meeting_id, 
 				name, 
 				number, 
 				visible, 
-				advertised_start_time 
-			FROM races
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				rank, 
+				meeting_timezone, 
+				meeting_open_race_count 
+			FROM (
+				SELECT 
+					*, 
+					ROW_NUMBER() OVER (PARTITION BY meeting_id ORDER BY number, id) AS meeting_position 
+				FROM (%s)
+			) 
+			WHERE meeting_position = 1
+		`,
+		// Wraps a (filtered) races query, keeping only the latest starting race per meeting.
+		racesLastPerMeeting: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				rank, 
+				meeting_timezone, 
+				meeting_open_race_count 
+			FROM (
+				SELECT 
+					*, 
+					ROW_NUMBER() OVER (PARTITION BY meeting_id ORDER BY datetime(advertised_start_time) DESC, id DESC) AS meeting_position 
+				FROM (%s)
+			) 
+			WHERE meeting_position = 1
+		`,
+		// Wraps a (filtered) races query, counting its races.
+		racesCount: `
+			SELECT COUNT(*) FROM (%s)
+		`,
+		// Wraps a (filtered) races query, selecting only the race IDs.
+		racesIDs: `
+			SELECT id FROM (%s)
+		`,
+		// Wraps a (filtered) races query, selecting the Unix time its races start at in order,
+		// for those that aren't cancelled.
+		racesUncancelledStartSeconds: `
+			SELECT CAST(strftime('%%s', advertised_start_time) AS INTEGER) AS start 
+			FROM (%s) 
+			WHERE advertised_start_time IS NOT NULL AND status != ` + statusLiteral(racing.RaceStatus_CANCELLED) + ` 
+			ORDER BY start
+		`,
+		// Wraps a (filtered) races query, selecting the Unix time its races start at, for those
+		// starting from the first placeholder up to the second.
+		racesStartSeconds: `
+			SELECT CAST(strftime('%%s', advertised_start_time) AS INTEGER) 
+			FROM (%s) 
+			WHERE datetime(advertised_start_time) >= datetime(?) AND datetime(advertised_start_time) < datetime(?)
+		`,
+		// Selects the highest race ID, or 0 when there are no races.
+		racesMaxID: `
+			SELECT COALESCE(MAX(id), 0) FROM races
+		`,
+		// Wraps a (filtered) races query, selecting the distinct race numbers of races that have
+		// one.
+		racesNumbers: `
+			SELECT DISTINCT number FROM (%s) WHERE number IS NOT NULL ORDER BY number
+		`,
+		// Wraps a (filtered) races query, counting its races in each status.
+		racesStatusSummary: `
+			SELECT 
+				COALESCE(SUM(CASE WHEN status = ` + statusLiteral(racing.RaceStatus_OPEN) + ` THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN status = ` + statusLiteral(racing.RaceStatus_CLOSED) + ` THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN status = ` + statusLiteral(racing.RaceStatus_CANCELLED) + ` THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN status = ` + statusLiteral(racing.RaceStatus_SETTLED) + ` THEN 1 ELSE 0 END), 0) 
+			FROM (%s)
+		`,
+		// Wraps a (filtered) races query, counting its visible and hidden races.
+		racesVisibilitySummary: `
+			SELECT 
+				COALESCE(SUM(CASE WHEN visible = 1 THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN visible = 1 THEN 0 ELSE 1 END), 0) 
+			FROM (%s)
+		`,
+		racesCancel: `
+			UPDATE races SET cancelled = 1, updated_at = ? WHERE id = ? AND purged = 0
+		`,
+		// Updates the race with the ID bound to the last placeholder, making the column
+		// assignments substituted in and recording the update time bound before it.
+		racesUpdate: `
+			UPDATE races SET %s, updated_at = ? WHERE id = ? AND purged = 0
+		`,
+		// Counts, deletes and marks as purged the races matching a purge condition, respectively.
+		racesPurgeCount: `
+			SELECT COUNT(*) FROM races WHERE %s
+		`,
+		racesPurge: `
+			DELETE FROM races WHERE %s
+		`,
+		racesSoftPurge: `
+			UPDATE races SET purged = 1 WHERE %s
+		`,
+		// Wraps a (filtered) races query, keeping only the soonest races per meeting, up to a
+		// bound limit.
+		racesPerMeetingLimit: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				rank, 
+				meeting_timezone, 
+				meeting_open_race_count 
+			FROM (
+				SELECT 
+					*, 
+					ROW_NUMBER() OVER (PARTITION BY meeting_id ORDER BY datetime(advertised_start_time), id) AS meeting_position 
+				FROM (%s)
+			) 
+			WHERE meeting_position <= ?
+		`,
+		// Wraps a (filtered) races query, keeping only the race at the position bound to the
+		// placeholder, from 0, among the races ordered by their start time.
+		racesNext: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				rank, 
+				meeting_timezone, 
+				meeting_open_race_count 
+			FROM (
+				SELECT * FROM (%s) ORDER BY datetime(advertised_start_time), id LIMIT 1 OFFSET ?
+			)
+		`,
+		// Selects the races that aren't cancelled and start at the same time as a race of another
+		// meeting, ordered by their start time.
+		racesStartClashes: `
+			SELECT 
+				datetime(advertised_start_time) AS start, 
+				id 
+			FROM races 
+			WHERE cancelled = 0 AND purged = 0 AND datetime(advertised_start_time) IN (
+				SELECT datetime(advertised_start_time) 
+				FROM races 
+				WHERE cancelled = 0 AND purged = 0 
+				GROUP BY datetime(advertised_start_time) 
+				HAVING COUNT(DISTINCT meeting_id) > 1
+			) 
+			ORDER BY start, id
+		`,
+		// Counts the races that haven't been purged with each kind of defect, in the order of
+		// the data quality report's fields. The defects match the admin filters reporting them.
+		racesDataQuality: `
+			SELECT 
+				COUNT(*), 
+				COALESCE(SUM(CASE WHEN meetings.id IS NULL THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN races.advertised_start_time IS NULL THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN races.number IS NULL OR races.number <= 0 THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN races.visible != meetings.visible THEN 1 ELSE 0 END), 0) 
+			FROM races 
+			LEFT JOIN meetings ON meetings.id = races.meeting_id 
+			WHERE races.purged = 0
+		`,
+		// Wraps a (filtered) races query, bucketing its races by their start time into buckets
+		// as wide as the seconds bound to both placeholders.
+		racesTimeline: `
+			SELECT 
+				(CAST(strftime('%%s', advertised_start_time) AS INTEGER) / ?) * ? AS bucket, 
+				id 
+			FROM (%s) 
+			WHERE advertised_start_time IS NOT NULL 
+			ORDER BY bucket, id
+		`,
+		// Makes the highest numbered race of each meeting without a feature race its feature race.
+		racesSeedFeatures: `
+			UPDATE races SET is_feature = 1 WHERE id IN (
+				SELECT id FROM (
+					SELECT 
+						id, 
+						ROW_NUMBER() OVER (PARTITION BY meeting_id ORDER BY number DESC, id) AS meeting_position 
+					FROM races 
+					WHERE meeting_id NOT IN (SELECT meeting_id FROM races WHERE is_feature = 1)
+				) 
+				WHERE meeting_position = 1
+			)
+		`,
+		// Gives every meeting without a timezone one of the timezones races are commonly run in.
+		racesSeedTimezones: `
+			UPDATE meetings SET timezone = CASE id % 6 
+				WHEN 0 THEN 'Australia/Sydney' 
+				WHEN 1 THEN 'Australia/Melbourne' 
+				WHEN 2 THEN 'Australia/Brisbane' 
+				WHEN 3 THEN 'Australia/Adelaide' 
+				WHEN 4 THEN 'Australia/Perth' 
+				ELSE 'Pacific/Auckland' 
+			END 
+			WHERE timezone = ''
+		`,
+		// Selects the stored start time of every race that has one, as written rather than as
+		// parsed by the driver.
+		racesStartTimes: `
+			SELECT id, CAST(advertised_start_time AS TEXT) FROM races WHERE advertised_start_time IS NOT NULL ORDER BY id
+		`,
+		// Wraps a (filtered) races query, ranking its races by their start time. Races without
+		// a start time rank last.
+		racesRank: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				ROW_NUMBER() OVER (
+					ORDER BY CASE WHEN advertised_start_time IS NULL THEN 1 ELSE 0 END, datetime(advertised_start_time), id
+				) AS rank, 
+				meeting_timezone, 
+				meeting_open_race_count 
+			FROM (%s)
+		`,
+		// Wraps a (filtered) races query, counting the open races of each race's meeting, whether
+		// or not they're among those returned. The placeholder is bound to the instant statuses
+		// are evaluated at.
+		racesMeetingCounts: `
+			SELECT 
+				id, 
+				meeting_id, 
+				name, 
+				number, 
+				visible, 
+				advertised_start_time, 
+				status, 
+				betting_open, 
+				rank, 
+				meeting_timezone, 
+				(
+					SELECT COUNT(*) FROM races AS meeting_races 
+					WHERE meeting_races.meeting_id = listed.meeting_id 
+					AND meeting_races.purged = 0 
+					AND meeting_races.cancelled = 0 
+					AND datetime(meeting_races.advertised_start_time) > datetime(?)
+				) AS meeting_open_race_count 
+			FROM (%s) AS listed
+		`,
+		racesInsert: `
+			INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)
+		`,
+		racesMeetingExists: `
+			SELECT EXISTS (SELECT 1 FROM meetings WHERE id = ?)
+		`,
+		// Selects whether the race with the ID bound to the second placeholder has run, being
+		// neither cancelled nor advertised to start after the first. No rows are selected if it
+		// doesn't exist or was purged.
+		racesHasRun: `
+			SELECT cancelled = 0 AND advertised_start_time IS NOT NULL AND datetime(advertised_start_time) <= datetime(?) 
+			FROM races 
+			WHERE id = ? AND purged = 0
+		`,
+		racesDeleteResult: `
+			DELETE FROM race_results WHERE race_id = ?
+		`,
+		racesInsertPlacing: `
+			INSERT INTO race_results(race_id, position, runner_number, runner_name, recorded_at) VALUES (?,?,?,?,?)
+		`,
+		// Selects the placings of the race with the bound ID in position order, unless it was
+		// purged.
+		racesResult: `
+			SELECT 
+				race_results.position, 
+				race_results.runner_number, 
+				race_results.runner_name, 
+				race_results.recorded_at 
+			FROM race_results 
+			JOIN races ON races.id = race_results.race_id AND races.purged = 0 
+			WHERE race_results.race_id = ? 
+			ORDER BY race_results.position
+		`,
+		// Deletes the results of races that no longer exist.
+		racesPurgeResults: `
+			DELETE FROM race_results WHERE race_id NOT IN (SELECT id FROM races)
+		`,
+	}
+}
+
+const (
+	meetingsList = "list"
+)
+
+func getMeetingQueries() map[string]string {
+	return map[string]string{
+		// Summarises the races of every meeting. The placeholders are bound to "now", in the
+		// order of the open, closed and next open race statuses.
+		meetingsList: `
+			SELECT 
+				meetings.id, 
+				meetings.name, 
+				meetings.visible, 
+				meetings.timezone, 
+				COALESCE(SUM(CASE WHEN ` + raceStatusExpression + ` = ` + statusLiteral(racing.RaceStatus_OPEN) + ` THEN 1 ELSE 0 END), 0), 
+				COALESCE(SUM(CASE WHEN ` + raceStatusExpression + ` = ` + statusLiteral(racing.RaceStatus_CLOSED) + ` THEN 1 ELSE 0 END), 0), 
+				MIN(CASE WHEN ` + raceStatusExpression + ` = ` + statusLiteral(racing.RaceStatus_OPEN) + ` THEN datetime(races.advertised_start_time) END), 
+				MIN(datetime(races.advertised_start_time)), 
+				MAX(datetime(races.advertised_start_time)) 
+			FROM meetings
+			LEFT JOIN races ON races.meeting_id = meetings.id AND races.purged = 0
+			GROUP BY meetings.id
+			ORDER BY meetings.id
 		`,
 	}
 }
+
+// raceStatusExpression derives the status of a race in SQL, as at the instant its placeholder is
+// bound to. It's the single definition of a races status, both selected for each race and used
+// to filter races by status, so the two can never disagree. Races with a recorded result are
+// settled, whatever the instant.
+var raceStatusExpression = fmt.Sprintf(
+	"(CASE WHEN races.cancelled = 1 THEN %d WHEN EXISTS (SELECT 1 FROM race_results WHERE race_results.race_id = races.id) THEN %d WHEN races.advertised_start_time IS NULL THEN %d WHEN datetime(races.advertised_start_time) > datetime(?) THEN %d ELSE %d END)",
+	racing.RaceStatus_CANCELLED,
+	racing.RaceStatus_SETTLED,
+	racing.RaceStatus_RACE_STATUS_UNSPECIFIED,
+	racing.RaceStatus_OPEN,
+	racing.RaceStatus_CLOSED,
+)
+
+// bettingOpenExpression derives whether a race is accepting bets, being any race that isn't
+// cancelled and starts after the instant its placeholder is bound to. It's bound to now plus the
+// betting cutoff, which is equivalent to now being before the start time less the cutoff.
+const bettingOpenExpression = "(races.cancelled = 0 AND datetime(races.advertised_start_time) > datetime(?))"
+
+// filterableStatuses are the race statuses races may be filtered by.
+var filterableStatuses = map[racing.RaceStatus]bool{
+	racing.RaceStatus_OPEN:      true,
+	racing.RaceStatus_CLOSED:    true,
+	racing.RaceStatus_CANCELLED: true,
+	racing.RaceStatus_SETTLED:   true,
+}
+
+// statusLiteral returns the SQL literal of a race status, as derived by raceStatusExpression.
+func statusLiteral(status racing.RaceStatus) string {
+	return strconv.Itoa(int(status))
+}
+
+// sortPresets maps each sort preset to the fixed expression races are ordered by. Races are
+// added in ID order, so the newest have the highest IDs.
+var sortPresets = map[racing.SortPreset]string{
+	racing.SortPreset_NEWEST: "id DESC",
+	// The status is that derived for the race, so the bands follow the instant it's derived at.
+	racing.SortPreset_STATUS: "CASE status" +
+		" WHEN " + statusLiteral(racing.RaceStatus_OPEN) + " THEN 0" +
+		" WHEN " + statusLiteral(racing.RaceStatus_CLOSED) + " THEN 1" +
+		" WHEN " + statusLiteral(racing.RaceStatus_SETTLED) + " THEN 2" +
+		" WHEN " + statusLiteral(racing.RaceStatus_CANCELLED) + " THEN 3" +
+		" ELSE 4 END, advertised_start_time IS NULL, datetime(advertised_start_time), id",
+}
+
+// orderColumns allowlists the columns races may be ordered by, other than their start time,
+// keyed by the field name a filter requests them by.
+var orderColumns = map[string]string{
+	"name":       "name",
+	"meeting_id": "meeting_id",
+	"number":     "number",
+}
+
+// sortColumns allowlists the expressions races may be sorted by, keyed by the field a filter
+// sorts them by. Races without a start time sort last by it, whatever the direction.
+var sortColumns = map[racing.OrderField]string{
+	racing.OrderField_START_TIME: "advertised_start_time IS NULL, datetime(advertised_start_time)",
+	racing.OrderField_NAME:       "name",
+	racing.OrderField_NUMBER:     "number",
+	racing.OrderField_MEETING:    "meeting_id",
+}
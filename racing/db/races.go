@@ -1,13 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/golang/protobuf/ptypes"
 	_ "github.com/mattn/go-sqlite3"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"git.neds.sh/matty/entain/order"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 )
 
@@ -17,17 +22,174 @@ type RacesRepo interface {
 	Init() error
 
 	// List will return a list of races.
-	List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error)
+	List(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]*racing.Race, error)
+
+	// Get will return the race with the given ID, or nil if there's no such race.
+	Get(ctx context.Context, id int64) (*racing.Race, error)
+
+	// GetByMeetingAndNumber will return the race of a meeting with the given number, or nil if
+	// there's no such race.
+	GetByMeetingAndNumber(ctx context.Context, meetingID, number int64) (*racing.Race, error)
+
+	// ListIDs will return the IDs of the races List would return.
+	ListIDs(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]int64, error)
+
+	// Count will return the number of races List would return, regardless of its limit and
+	// offset.
+	Count(ctx context.Context, filter *racing.ListRacesRequestFilter) (int64, error)
+
+	// MaxID will return the highest race ID, or 0 when there are no races.
+	MaxID(ctx context.Context) (int64, error)
+
+	// ListNumbers will return the distinct numbers of the races in the given meetings, or in
+	// all meetings when none are given.
+	ListNumbers(ctx context.Context, meetingIDs []int64) ([]int64, error)
+
+	// StatusSummary will return the number of races List would return in each status.
+	StatusSummary(ctx context.Context, filter *racing.ListRacesRequestFilter) (*racing.StatusSummary, error)
+
+	// VisibilitySummary will return the number of races List would return that are visible and
+	// hidden.
+	VisibilitySummary(ctx context.Context, filter *racing.ListRacesRequestFilter) (*racing.VisibilitySummary, error)
+
+	// Timeline will return the races List would return, grouped by start time into buckets of
+	// the given width.
+	Timeline(ctx context.Context, filter *racing.ListRacesRequestFilter, width time.Duration) ([]*racing.TimelineBucket, error)
+
+	// HourlyHistogram will return the number of races List would return starting in each hour
+	// of the given date in the location, or the repository's location when nil.
+	HourlyHistogram(ctx context.Context, filter *racing.ListRacesRequestFilter, date string, location *time.Location) ([]*racing.HourlyCount, error)
+
+	// OpenClosedTrend will return the number of races List would return that were open and
+	// closed at the start of each interval of the given width over the given date in the
+	// location, or the repository's location when nil.
+	OpenClosedTrend(ctx context.Context, filter *racing.ListRacesRequestFilter, date string, width time.Duration, location *time.Location) ([]*racing.TrendPoint, error)
+
+	// StartTimeClashes will return the start times shared by races of different meetings.
+	StartTimeClashes(ctx context.Context) ([]*racing.StartTimeClash, error)
+
+	// DataQuality will return the number of races with each kind of data defect.
+	DataQuality(ctx context.Context) (*racing.DataQualityReport, error)
+
+	// InsertBatch will insert the given races within a single transaction.
+	InsertBatch(ctx context.Context, races []*racing.Race) error
+
+	// Create will insert a race of an existing meeting, returning the stored race.
+	Create(ctx context.Context, race *racing.Race) (*racing.Race, error)
+
+	// Cancel will mark a race as cancelled, returning the updated race.
+	Cancel(ctx context.Context, id int64) (*racing.Race, error)
+
+	// Update will update the named fields of the race with the same ID, returning the updated
+	// race.
+	Update(ctx context.Context, race *racing.Race, fields []string) (*racing.Race, error)
+
+	// Purge will remove the races that started before the given time, returning how many were
+	// removed, or only count them on a dry run.
+	Purge(ctx context.Context, before time.Time, dryRun bool) (int64, error)
+
+	// SetResult will record the placings of a race that has run, returning the result.
+	SetResult(ctx context.Context, raceID int64, placings []*racing.Placing) (*racing.RaceResult, error)
+
+	// GetResult will return the result recorded for a race.
+	GetResult(ctx context.Context, raceID int64) (*racing.RaceResult, error)
+
+	// Close will close the database, along with it every repository over it, such as of
+	// meetings.
+	Close() error
 }
 
+// likeEscaper escapes LIKE wildcards, and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type racesRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db            *sql.DB
+	init          sync.Once
+	limiter       *QueryLimiter
+	holidays      []string
+	bettingCutoff time.Duration
+	strict        bool
+	softPurge     bool
+	cache         *raceCache
+	location      *time.Location
+
+	businessHoursStart, businessHoursEnd string
+}
+
+// RacesRepoOption configures optional behaviour of a races repository.
+type RacesRepoOption func(*racesRepo)
+
+// WithHolidays sets the YYYY-MM-DD dates treated as public holidays.
+func WithHolidays(holidays []string) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.holidays = holidays
+	}
+}
+
+// WithBettingCutoff sets how long before its advertised start time betting on a race closes.
+func WithBettingCutoff(cutoff time.Duration) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.bettingCutoff = cutoff
+	}
+}
+
+// WithStrictTimestamps makes Init fail if any race has a start time that isn't RFC3339, and
+// reading such a race fail naming it, rather than parsing the start time as best it can.
+func WithStrictTimestamps() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.strict = true
+	}
+}
+
+// WithSoftPurge makes Purge mark races as purged, hiding them from then on, rather than deleting
+// them.
+func WithSoftPurge() RacesRepoOption {
+	return func(r *racesRepo) {
+		r.softPurge = true
+	}
+}
+
+// WithRaceCache makes Get serve races from a cache for up to ttl after fetching them, until they
+// change. Their status, and whether betting is open, are still those as at the time they're
+// served.
+func WithRaceCache(ttl time.Duration) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.cache = newRaceCache(ttl)
+	}
+}
+
+// WithLocation sets the timezone dates are in, such as those of holidays, in place of the
+// servers local time. It's also the timezone local start times are formatted in when neither
+// the request nor the race's meeting has one.
+func WithLocation(location *time.Location) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.location = location
+	}
+}
+
+// WithBusinessHours sets the HH:MM clock times business hours start and end at, in place of
+// 09:00 and 17:00, as parsed by ParseBusinessHours.
+func WithBusinessHours(start, end string) RacesRepoOption {
+	return func(r *racesRepo) {
+		r.businessHoursStart, r.businessHoursEnd = start, end
+	}
+}
+
+// NewRacesRepo creates a new races repository, bounding its concurrent queries by the given
+// limiter.
+func NewRacesRepo(db *sql.DB, limiter *QueryLimiter, opts ...RacesRepoOption) RacesRepo {
+	r := &racesRepo{db: db, limiter: limiter}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
-// NewRacesRepo creates a new races repository.
-func NewRacesRepo(db *sql.DB) RacesRepo {
-	return &racesRepo{db: db}
+// Close closes the database, waiting for queries already made to finish.
+func (r *racesRepo) Close() error {
+	return r.db.Close()
 }
 
 // Init prepares the race repository dummy data.
@@ -37,12 +199,23 @@ func (r *racesRepo) Init() error {
 	r.init.Do(func() {
 		// For test/example purposes, we seed the DB with some dummy races.
 		err = r.seed()
+		if err == nil {
+			err = r.migrate()
+		}
+		if err == nil && r.strict {
+			err = r.checkStartTimes()
+		}
 	})
 
 	return err
 }
 
-func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+func (r *racesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
 	var (
 		err   error
 		query string
@@ -51,67 +224,1363 @@ func (r *racesRepo) List(filter *racing.ListRacesRequestFilter) ([]*racing.Race,
 
 	query = getRaceQueries()[racesList]
 
-	query, args = r.applyFilter(query, filter)
+	location, err := localTime(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args = r.applyFilter(query, filter, statusTime(filter))
+	query, args = r.applyOrder(query, args, filter)
+	query, args = r.applyPage(query, args, filter)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.scanRaces(rows)
+	return r.scanRaces(rows, location)
 }
 
-func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
-	var (
-		clauses []string
-		args    []interface{}
+// ListIDs returns the IDs of the races matching the filter, in the same order as List.
+func (r *racesRepo) ListIDs(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]int64, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	query, args = r.applyOrder(fmt.Sprintf(getRaceQueries()[racesIDs], query), args, filter)
+	query, args = r.applyPage(query, args, filter)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Count returns the number of races matching the filter, as List would return them were it
+// not limited to a page of them.
+func (r *racesRepo) Count(ctx context.Context, filter *racing.ListRacesRequestFilter) (int64, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	var count int64
+
+	if err := r.db.QueryRowContext(ctx, fmt.Sprintf(getRaceQueries()[racesCount], query), args...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MaxID returns the highest race ID. Races are assigned increasing IDs as they're inserted, so
+// the races present at the time have IDs up to it.
+func (r *racesRepo) MaxID(ctx context.Context) (int64, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer r.limiter.release()
+
+	var id int64
+
+	err := r.db.QueryRowContext(ctx, getRaceQueries()[racesMaxID]).Scan(&id)
+
+	return id, err
+}
+
+// ListNumbers returns the distinct numbers of the races that aren't cancelled, in ascending
+// order.
+func (r *racesRepo) ListNumbers(ctx context.Context, meetingIDs []int64) ([]int64, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], &racing.ListRacesRequestFilter{MeetingIds: meetingIDs}, time.Now())
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(getRaceQueries()[racesNumbers], query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var numbers []int64
+
+	for rows.Next() {
+		var number int64
+
+		if err := rows.Scan(&number); err != nil {
+			return nil, err
+		}
+
+		numbers = append(numbers, number)
+	}
+
+	return numbers, rows.Err()
+}
+
+// ErrMeetingNotFound is returned when a race is created for a meeting that doesn't exist.
+var ErrMeetingNotFound = errors.New("meeting not found")
+
+// Create inserts the race, assigning it an ID, and returns it as stored, with its status derived
+// as at now. Only its meeting, name, number, visibility and start time are stored. It returns
+// ErrMeetingNotFound if the race's meeting doesn't exist.
+func (r *racesRepo) Create(ctx context.Context, race *racing.Race) (*racing.Race, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, getRaceQueries()[racesMeetingExists], race.MeetingId).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrMeetingNotFound
+	}
+
+	advertisedStart, err := ptypes.Timestamp(race.AdvertisedStartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs are always assigned by the database, whatever the race's ID.
+	result, err := tx.ExecContext(ctx,
+		getRaceQueries()[racesInsert],
+		nil,
+		race.MeetingId,
+		race.Name,
+		race.Number,
+		race.Visible,
+		advertisedStart.Format(time.RFC3339),
 	)
+	if err != nil {
+		return nil, err
+	}
 
-	if filter == nil {
-		return query, args
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
 	}
 
-	if len(filter.MeetingIds) > 0 {
-		clauses = append(clauses, "meeting_id IN ("+strings.Repeat("?,", len(filter.MeetingIds)-1)+"?)")
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-		for _, meetingID := range filter.MeetingIds {
-			args = append(args, meetingID)
+	r.cache.invalidate(id)
+
+	return r.get(ctx, "races.id = ?", id)
+}
+
+// Cancel marks the race with the given ID as cancelled, as updated now, and returns it, or nil
+// if no such race exists.
+func (r *racesRepo) Cancel(ctx context.Context, id int64) (*racing.Race, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	result, err := r.db.ExecContext(ctx, getRaceQueries()[racesCancel], time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.invalidate(id)
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return nil, err
+	}
+
+	return r.get(ctx, "races.id = ?", id)
+}
+
+// ErrRaceNotRun is returned when a result is recorded for a race that was cancelled or hasn't
+// started yet.
+var ErrRaceNotRun = errors.New("race hasn't run")
+
+// SetResult records the placings of the race with the given ID, replacing any it already has,
+// and returns its result, or nil if no such race exists or it was purged. It returns
+// ErrRaceNotRun unless the race has started as at now and wasn't cancelled.
+func (r *racesRepo) SetResult(ctx context.Context, raceID int64, placings []*racing.Placing) (*racing.RaceResult, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+
+	var hasRun bool
+	err = tx.QueryRowContext(ctx, getRaceQueries()[racesHasRun], now.Format(time.RFC3339), raceID).Scan(&hasRun)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasRun {
+		return nil, ErrRaceNotRun
+	}
+
+	if _, err := tx.ExecContext(ctx, getRaceQueries()[racesDeleteResult], raceID); err != nil {
+		return nil, err
+	}
+
+	for _, placing := range placings {
+		if _, err := tx.ExecContext(ctx,
+			getRaceQueries()[racesInsertPlacing],
+			raceID,
+			placing.Position,
+			placing.RunnerNumber,
+			placing.RunnerName,
+			now.Format(time.RFC3339),
+		); err != nil {
+			return nil, err
 		}
 	}
 
-	if len(clauses) != 0 {
-		query += " WHERE " + strings.Join(clauses, " AND ")
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return query, args
+	// The race is now settled, so its cached status is stale.
+	r.cache.invalidate(raceID)
+
+	return r.getResult(ctx, raceID)
 }
 
-func (m *racesRepo) scanRaces(
-	rows *sql.Rows,
-) ([]*racing.Race, error) {
-	var races []*racing.Race
+// GetResult returns the result recorded for the race with the given ID, or nil if it has none,
+// or no such race exists or it was purged.
+func (r *racesRepo) GetResult(ctx context.Context, raceID int64) (*racing.RaceResult, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	return r.getResult(ctx, raceID)
+}
+
+// getResult selects the result of the race with the given ID.
+func (r *racesRepo) getResult(ctx context.Context, raceID int64) (*racing.RaceResult, error) {
+	rows, err := r.db.QueryContext(ctx, getRaceQueries()[racesResult], raceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result *racing.RaceResult
 
 	for rows.Next() {
-		var race racing.Race
-		var advertisedStart time.Time
+		var (
+			placing    racing.Placing
+			recordedAt string
+		)
 
-		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, nil
+		if err := rows.Scan(&placing.Position, &placing.RunnerNumber, &placing.RunnerName, &recordedAt); err != nil {
+			return nil, err
+		}
+
+		// Every placing is recorded at once, so any of them has the time the result was.
+		if result == nil {
+			recorded, err := time.Parse(time.RFC3339, recordedAt)
+			if err != nil {
+				return nil, err
 			}
 
+			ts, err := ptypes.TimestampProto(recorded)
+			if err != nil {
+				return nil, err
+			}
+
+			result = &racing.RaceResult{RaceId: raceID, RecordedAt: ts}
+		}
+
+		result.Placings = append(result.Placings, &placing)
+	}
+
+	return result, rows.Err()
+}
+
+// Update sets the named fields of the race with the same ID to their values in race, leaving
+// its other fields untouched and recording it as updated now, and returns the updated race, or
+// nil if no such race exists or it was purged. Only the name, number, visibility and start time may be updated.
+func (r *racesRepo) Update(ctx context.Context, race *racing.Race, fields []string) (*racing.Race, error) {
+	var (
+		assignments []string
+		args        []interface{}
+	)
+
+	// Each field is stored in the column of the same name.
+	for _, field := range fields {
+		value, err := updateValue(race, field)
+		if err != nil {
 			return nil, err
 		}
 
-		ts, err := ptypes.TimestampProto(advertisedStart)
+		assignments = append(assignments, field+" = ?")
+		args = append(args, value)
+	}
+
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query := fmt.Sprintf(getRaceQueries()[racesUpdate], strings.Join(assignments, ", "))
+
+	result, err := r.db.ExecContext(ctx, query, append(args, time.Now().Format(time.RFC3339), race.Id)...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.invalidate(race.Id)
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return nil, err
+	}
+
+	return r.get(ctx, "races.id = ?", race.Id)
+}
+
+// updateValue returns the value of a field of the race, as stored in its column, or an error if
+// the field can't be updated. Start times are stored as RFC3339, or NULL when the race has none.
+func updateValue(race *racing.Race, field string) (interface{}, error) {
+	switch field {
+	case "name":
+		return race.Name, nil
+	case "number":
+		return race.Number, nil
+	case "visible":
+		return race.Visible, nil
+	case "advertised_start_time":
+		if race.AdvertisedStartTime == nil {
+			return nil, nil
+		}
+
+		start, err := ptypes.Timestamp(race.AdvertisedStartTime)
 		if err != nil {
 			return nil, err
 		}
 
-		race.AdvertisedStartTime = ts
+		return start.Format(time.RFC3339), nil
+	default:
+		return nil, fmt.Errorf("race field %q can't be updated", field)
+	}
+}
+
+// Get returns the race with the given ID, whether or not it's visible or cancelled, or nil if
+// no such race exists or it was purged.
+func (r *racesRepo) Get(ctx context.Context, id int64) (*racing.Race, error) {
+	if race := r.cache.get(id); race != nil {
+		r.refreshStatus(race, time.Now())
+		return race, nil
+	}
 
-		races = append(races, &race)
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	// A write made while the race is fetched invalidates it, so the race is then left uncached.
+	fetched := r.cache.generation(id)
+
+	race, err := r.get(ctx, "races.id = ?", id)
+	if err != nil || race == nil {
+		return nil, err
+	}
+
+	r.cache.put(race, fetched)
+
+	return race, nil
+}
+
+// refreshStatus rederives the status of a race fetched earlier, and whether it's accepting bets,
+// as at now, as they would be selected. Only open and closed races change with time; the others
+// only change when the race is written to, which drops it from the cache.
+func (r *racesRepo) refreshStatus(race *racing.Race, now time.Time) {
+	if race.AdvertisedStartTime == nil || (race.Status != racing.RaceStatus_OPEN && race.Status != racing.RaceStatus_CLOSED) {
+		return
+	}
+
+	// Start times are compared to the second, as by the database.
+	start := race.AdvertisedStartTime.AsTime()
+
+	race.Status = racing.RaceStatus_CLOSED
+	if start.After(now.Truncate(time.Second)) {
+		race.Status = racing.RaceStatus_OPEN
+	}
+
+	race.BettingOpen = start.After(now.Add(r.bettingCutoff).Truncate(time.Second))
+}
+
+// GetByMeetingAndNumber returns the race of the meeting with the given number, or nil if no
+// such race exists. Should the meeting have several races with the number, the first added is
+// returned.
+func (r *racesRepo) GetByMeetingAndNumber(ctx context.Context, meetingID, number int64) (*racing.Race, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	return r.get(ctx, "races.meeting_id = ? AND races.number = ? ORDER BY races.id LIMIT 1", meetingID, number)
+}
+
+// get selects the first race matching the clause, with its status derived as at now as for
+// any listed race. Purged races never match.
+func (r *racesRepo) get(ctx context.Context, clause string, args ...interface{}) (*racing.Race, error) {
+	rows, err := r.db.QueryContext(ctx, getRaceQueries()[racesList]+" WHERE races.purged = 0 AND "+clause, append(r.listArgs(time.Now()), args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	races, err := r.scanRaces(rows, nil)
+	if err != nil || len(races) == 0 {
+		return nil, err
+	}
+
+	return races[0], nil
+}
+
+// StatusSummary counts the races matching the filter in each status, using a single query.
+func (r *racesRepo) StatusSummary(ctx context.Context, filter *racing.ListRacesRequestFilter) (*racing.StatusSummary, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	var counts racing.StatusSummary
+
+	if err := r.db.QueryRowContext(ctx, fmt.Sprintf(getRaceQueries()[racesStatusSummary], query), args...).Scan(&counts.Open, &counts.Closed, &counts.Cancelled, &counts.Settled); err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+// VisibilitySummary counts the races matching the filter that are visible and hidden, ignoring
+// the filter's limit and offset.
+func (r *racesRepo) VisibilitySummary(ctx context.Context, filter *racing.ListRacesRequestFilter) (*racing.VisibilitySummary, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	var counts racing.VisibilitySummary
+
+	if err := r.db.QueryRowContext(ctx, fmt.Sprintf(getRaceQueries()[racesVisibilitySummary], query), args...).Scan(&counts.Visible, &counts.Hidden); err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+// HourlyHistogram counts the races matching the filter starting in each hour of the day, being
+// the YYYY-MM-DD date in the location. Hours are those on the clock, so a day with a daylight
+// saving transition has an hour with no races, or one counting two hours of races.
+func (r *racesRepo) HourlyHistogram(ctx context.Context, filter *racing.ListRacesRequestFilter, date string, location *time.Location) ([]*racing.HourlyCount, error) {
+	if location == nil {
+		location = r.location
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	start, err := time.ParseInLocation(holidayLayout, date, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+	args = append(args, start.Format(time.RFC3339), start.AddDate(0, 0, 1).Format(time.RFC3339))
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(getRaceQueries()[racesStartSeconds], query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := make([]*racing.HourlyCount, 24)
+	for hour := range hours {
+		hours[hour] = &racing.HourlyCount{Hour: int64(hour)}
+	}
+
+	for rows.Next() {
+		var seconds int64
+
+		if err := rows.Scan(&seconds); err != nil {
+			return nil, err
+		}
+
+		hours[time.Unix(seconds, 0).In(location).Hour()].Count++
+	}
+
+	return hours, rows.Err()
+}
+
+// OpenClosedTrend counts the races matching the filter that were open and closed at the start
+// of each interval of the width, from the start of the YYYY-MM-DD date in the location up to
+// its end. Races are open until their advertised start time, as their status is derived.
+func (r *racesRepo) OpenClosedTrend(ctx context.Context, filter *racing.ListRacesRequestFilter, date string, width time.Duration, location *time.Location) ([]*racing.TrendPoint, error) {
+	if location == nil {
+		location = r.location
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	start, err := time.ParseInLocation(holidayLayout, date, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(getRaceQueries()[racesUncancelledStartSeconds], query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var starts []int64
+
+	for rows.Next() {
+		var seconds int64
+
+		if err := rows.Scan(&seconds); err != nil {
+			return nil, err
+		}
+
+		starts = append(starts, seconds)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var points []*racing.TrendPoint
+
+	// The day is stepped through in elapsed time, so a day with a daylight saving transition
+	// has more or fewer points.
+	for at, end := start, start.AddDate(0, 0, 1); at.Before(end); at = at.Add(width) {
+		ts, err := ptypes.TimestampProto(at)
+		if err != nil {
+			return nil, err
+		}
+
+		// Starts are ordered, so those up to the instant are the races closed by then.
+		closed := sort.Search(len(starts), func(i int) bool { return starts[i] > at.Unix() })
+
+		points = append(points, &racing.TrendPoint{
+			At:     ts,
+			Open:   int64(len(starts) - closed),
+			Closed: int64(closed),
+		})
+	}
+
+	return points, nil
+}
+
+// DataQuality counts the races with each kind of data defect, among every race that hasn't been
+// purged.
+func (r *racesRepo) DataQuality(ctx context.Context) (*racing.DataQualityReport, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	var report racing.DataQualityReport
+
+	if err := r.db.QueryRowContext(ctx, getRaceQueries()[racesDataQuality]).Scan(
+		&report.Races,
+		&report.Orphans,
+		&report.MissingStartTimes,
+		&report.InvalidNumbers,
+		&report.VisibilityMismatches,
+	); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// StartTimeClashes returns every start time shared by races of more than one meeting, with the
+// races starting then. Cancelled races don't clash.
+func (r *racesRepo) StartTimeClashes(ctx context.Context) ([]*racing.StartTimeClash, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	rows, err := r.db.QueryContext(ctx, getRaceQueries()[racesStartClashes])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		clashes []*racing.StartTimeClash
+		clash   *racing.StartTimeClash
+		last    string
+	)
+
+	for rows.Next() {
+		var (
+			start string
+			id    int64
+		)
+
+		if err := rows.Scan(&start, &id); err != nil {
+			return nil, err
+		}
+
+		// Rows are ordered by start time, so each new start time begins the next clash.
+		if clash == nil || start != last {
+			startTime, err := time.Parse(sqliteDateTime, start)
+			if err != nil {
+				return nil, err
+			}
+
+			ts, err := ptypes.TimestampProto(startTime)
+			if err != nil {
+				return nil, err
+			}
+
+			clash = &racing.StartTimeClash{AdvertisedStartTime: ts}
+			clashes = append(clashes, clash)
+			last = start
+		}
+
+		clash.RaceIds = append(clash.RaceIds, id)
+	}
+
+	return clashes, rows.Err()
+}
+
+// Timeline buckets the races matching the filter by their start time, truncated to the width
+// since the Unix epoch. Races without a start time are left out.
+func (r *racesRepo) Timeline(ctx context.Context, filter *racing.ListRacesRequestFilter, width time.Duration) ([]*racing.TimelineBucket, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer r.limiter.release()
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], filter, statusTime(filter))
+
+	// The width placeholders precede those of the wrapped query.
+	seconds := int64(width / time.Second)
+	args = append([]interface{}{seconds, seconds}, args...)
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(getRaceQueries()[racesTimeline], query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		buckets []*racing.TimelineBucket
+		bucket  *racing.TimelineBucket
+		last    int64
+	)
+
+	for rows.Next() {
+		var start, id int64
+
+		if err := rows.Scan(&start, &id); err != nil {
+			return nil, err
+		}
+
+		// Rows are ordered by bucket, so each new bucket start begins the next bucket.
+		if bucket == nil || start != last {
+			ts, err := ptypes.TimestampProto(time.Unix(start, 0))
+			if err != nil {
+				return nil, err
+			}
+
+			bucket = &racing.TimelineBucket{Start: ts}
+			buckets = append(buckets, bucket)
+			last = start
+		}
+
+		bucket.Count++
+		bucket.RaceIds = append(bucket.RaceIds, id)
+	}
+
+	return buckets, rows.Err()
+}
+
+// InsertBatch inserts all of the given races using a single prepared statement and
+// transaction, so either every race is inserted or none are. Races without an ID are
+// assigned one by the database.
+func (r *racesRepo) InsertBatch(ctx context.Context, races []*racing.Race) error {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer r.limiter.release()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := r.insertRaces(ctx, tx, races); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		r.cache.invalidate(race.Id)
+	}
+
+	return nil
+}
+
+func (r *racesRepo) insertRaces(ctx context.Context, tx *sql.Tx, races []*racing.Race) error {
+	statement, err := tx.PrepareContext(ctx, getRaceQueries()[racesInsert])
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, race := range races {
+		advertisedStart, err := ptypes.Timestamp(race.AdvertisedStartTime)
+		if err != nil {
+			return err
+		}
+
+		if _, err := statement.Exec(
+			sql.NullInt64{Int64: race.Id, Valid: race.Id != 0},
+			race.MeetingId,
+			race.Name,
+			race.Number,
+			race.Visible,
+			advertisedStart.Format(time.RFC3339),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Purge removes the races advertised to start before the given time, deleting them unless soft
+// purging, and returns how many were removed. A dry run only counts the races it would remove.
+// Races without a start time are never purged.
+func (r *racesRepo) Purge(ctx context.Context, before time.Time, dryRun bool) (int64, error) {
+	if err := r.limiter.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer r.limiter.release()
+
+	condition := "advertised_start_time IS NOT NULL AND datetime(advertised_start_time) < datetime(?)"
+	if r.softPurge {
+		condition += " AND purged = 0"
+	}
+
+	cutoff := before.Format(time.RFC3339)
+
+	if dryRun {
+		var count int64
+
+		err := r.db.QueryRowContext(ctx, fmt.Sprintf(getRaceQueries()[racesPurgeCount], condition), cutoff).Scan(&count)
+
+		return count, err
+	}
+
+	purge := getRaceQueries()[racesPurge]
+	if r.softPurge {
+		purge = getRaceQueries()[racesSoftPurge]
+	}
+
+	result, err := r.db.ExecContext(ctx, fmt.Sprintf(purge, condition), cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	r.cache.clear()
+
+	// Soft purged races keep their results, as they would anything else.
+	if !r.softPurge {
+		if _, err := r.db.ExecContext(ctx, getRaceQueries()[racesPurgeResults]); err != nil {
+			return 0, err
+		}
+	}
+
+	return result.RowsAffected()
+}
+
+// applyFilter filters the races list query, with race statuses evaluated as at now.
+func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter, now time.Time) (string, []interface{}) {
+	var (
+		clauses []string
+		args    = r.listArgs(now)
+	)
+
+	// An absent filter still applies the defaults, such as hiding cancelled races.
+	if filter == nil {
+		filter = &racing.ListRacesRequestFilter{}
+	}
+
+	clauses = append(clauses, "races.purged = 0")
+
+	if len(filter.MeetingIds) > 0 {
+		clauses = append(clauses, "races.meeting_id IN ("+strings.Repeat("?,", len(filter.MeetingIds)-1)+"?)")
+
+		for _, meetingID := range filter.MeetingIds {
+			args = append(args, meetingID)
+		}
+	}
+
+	if len(filter.Ids) > 0 {
+		clauses = append(clauses, "races.id IN ("+strings.Repeat("?,", len(filter.Ids)-1)+"?)")
+
+		for _, id := range filter.Ids {
+			args = append(args, id)
+		}
+	}
+
+	if filter.IdAfter > 0 {
+		clauses = append(clauses, "races.id > ?")
+		args = append(args, filter.IdAfter)
+	}
+
+	if filter.SnapshotId > 0 {
+		clauses = append(clauses, "races.id <= ?")
+		args = append(args, filter.SnapshotId)
+	}
+
+	if filter.VisibleOnly {
+		clauses = append(clauses, "races.visible = 1")
+	}
+
+	if filter.Search != "" {
+		clauses = append(clauses, `(races.name LIKE ? ESCAPE '\' OR meetings.name LIKE ? ESCAPE '\')`)
+		args = append(args, "%"+escapeLike(filter.Search)+"%", "%"+escapeLike(filter.Search)+"%")
+	}
+
+	// The whole pattern is bound, rather than concatenated in SQL, so the prefix matches can be
+	// served by the name indexes.
+	if filter.NamePrefix != "" {
+		clauses = append(clauses, `races.name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(filter.NamePrefix)+"%")
+	}
+
+	if len(filter.Names) > 0 {
+		column := "races.name"
+		if filter.NamesCaseInsensitive {
+			column += " COLLATE NOCASE"
+		}
+
+		clauses = append(clauses, column+" IN ("+strings.Repeat("?,", len(filter.Names)-1)+"?)")
+
+		for _, name := range filter.Names {
+			args = append(args, name)
+		}
+	}
+
+	if filter.MeetingNamePrefix != "" {
+		clauses = append(clauses, `meetings.name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(filter.MeetingNamePrefix)+"%")
+	}
+
+	if filter.FeatureOnly {
+		clauses = append(clauses, "races.is_feature = 1")
+	}
+
+	if filter.MeetingVisibility != nil {
+		clauses = append(clauses, "meetings.visible = ?")
+		args = append(args, filter.MeetingVisibility.Value)
+	}
+
+	if filter.InActiveMeeting {
+		clauses = append(clauses, "meetings.visible = 1")
+	}
+
+	// Open races are those yet to start, which the start time index can find without deriving
+	// the status of every race.
+	if filter.ShowClosed != nil && !filter.ShowClosed.Value {
+		clauses = append(clauses, raceStatusExpression+" = ?", "datetime(races.advertised_start_time) > datetime(?)")
+		args = append(args, now.Format(time.RFC3339), racing.RaceStatus_OPEN, now.Format(time.RFC3339))
+	}
+
+	if filter.OnHoliday != nil {
+		clause, holidayArgs := r.holidayClause(filter.OnHoliday.Value)
+
+		clauses = append(clauses, clause)
+		args = append(args, holidayArgs...)
+	}
+
+	if filter.BettingOpenOnly {
+		clauses = append(clauses, bettingOpenExpression)
+		args = append(args, r.bettingClose(now))
+	}
+
+	if filter.NextOnly || filter.NthUpcoming > 0 {
+		clauses = append(clauses, "races.visible = 1", raceStatusExpression+" = ?")
+		args = append(args, now.Format(time.RFC3339), racing.RaceStatus_OPEN)
+	}
+
+	// The interval excludes since, so consecutive polls don't both return a race closing at the
+	// instant between them.
+	if filter.ClosedSince != nil {
+		clauses = append(clauses, "races.cancelled = 0 AND datetime(races.advertised_start_time) > datetime(?) AND datetime(races.advertised_start_time) <= datetime(?)")
+		args = append(args, filter.ClosedSince.AsTime().Format(time.RFC3339), now.Format(time.RFC3339))
+	}
+
+	if filter.NameRegexp != "" {
+		clauses = append(clauses, "races.name REGEXP ?")
+		args = append(args, filter.NameRegexp)
+	}
+
+	if filter.StartTimeAfter != nil {
+		clauses = append(clauses, "datetime(races.advertised_start_time) > datetime(?)")
+		args = append(args, filter.StartTimeAfter.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.StartTimeBefore != nil {
+		clauses = append(clauses, "datetime(races.advertised_start_time) < datetime(?)")
+		args = append(args, filter.StartTimeBefore.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.UpdatedBetween != nil {
+		clauses = append(clauses, "datetime(races.updated_at) BETWEEN datetime(?) AND datetime(?)")
+		args = append(args, filter.UpdatedBetween.After.AsTime().Format(time.RFC3339), filter.UpdatedBetween.Before.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.BusinessHoursOnly {
+		// The timezone is validated along with the rest of the filter.
+		location, _ := localTime(filter)
+		if location == nil {
+			location = r.location
+		}
+		if location == nil {
+			location = time.Local
+		}
+
+		clause, hoursArgs := r.businessHoursClause(location, now)
+		clauses = append(clauses, clause)
+		args = append(args, hoursArgs...)
+	}
+
+	if filter.StillOpenInSeconds > 0 {
+		clauses = append(clauses, "races.cancelled = 0 AND datetime(races.advertised_start_time) > datetime(?)")
+		args = append(args, now.Add(time.Duration(filter.StillOpenInSeconds)*time.Second).Format(time.RFC3339))
+	}
+
+	if filter.VisibleInHiddenMeeting {
+		clauses = append(clauses, "races.visible = 1 AND meetings.visible = 0")
+	}
+
+	if filter.VisibilityMismatch {
+		clauses = append(clauses, "races.visible != meetings.visible")
+	}
+
+	if filter.OrphansOnly {
+		clauses = append(clauses, "meetings.id IS NULL")
+	}
+
+	if filter.InvalidNumber {
+		clauses = append(clauses, "(races.number IS NULL OR races.number <= 0)")
+	}
+
+	if !filter.IncludeCancelled && !containsStatus(filter.Statuses, racing.RaceStatus_CANCELLED) {
+		clauses = append(clauses, "races.cancelled = 0")
+	}
+
+	if len(filter.Statuses) > 0 {
+		var (
+			statuses []interface{}
+			seen     = make(map[racing.RaceStatus]bool)
+		)
+
+		for _, status := range filter.Statuses {
+			if !filterableStatuses[status] || seen[status] {
+				continue
+			}
+
+			seen[status] = true
+			statuses = append(statuses, status)
+		}
+
+		// Statuses are alternatives, matched against the same expression the status column is
+		// selected with.
+		if len(statuses) > 0 {
+			clause := raceStatusExpression + " IN (" + strings.Repeat("?,", len(statuses)-1) + "?)"
+			args = append(append(args, now.Format(time.RFC3339)), statuses...)
+
+			// Races closed within the grace window are listed along with the open races.
+			if grace := closedGrace(filter); grace > 0 && seen[racing.RaceStatus_OPEN] && !seen[racing.RaceStatus_CLOSED] {
+				clause = "(" + clause + " OR (races.cancelled = 0 AND datetime(races.advertised_start_time) > datetime(?) AND datetime(races.advertised_start_time) <= datetime(?)))"
+				args = append(args, now.Add(-grace).Format(time.RFC3339), now.Format(time.RFC3339))
+			}
+
+			clauses = append(clauses, clause)
+		}
+
+		// Open races are those yet to start, or that started within the grace window, which the
+		// start time index can find without deriving the status of every race.
+		if len(statuses) == 1 && statuses[0] == racing.RaceStatus_OPEN {
+			clauses = append(clauses, "datetime(races.advertised_start_time) > datetime(?)")
+			args = append(args, now.Add(-closedGrace(filter)).Format(time.RFC3339))
+		}
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	if filter.FirstRacePerMeeting {
+		query = fmt.Sprintf(getRaceQueries()[racesFirstPerMeeting], query)
+	}
+
+	if filter.LastRacePerMeeting {
+		query = fmt.Sprintf(getRaceQueries()[racesLastPerMeeting], query)
+	}
+
+	if filter.PerMeetingLimit > 0 {
+		query = fmt.Sprintf(getRaceQueries()[racesPerMeetingLimit], query)
+		args = append(args, filter.PerMeetingLimit)
+	}
+
+	// The next race is the first upcoming race. Without any, none are listed.
+	if filter.NextOnly {
+		query = fmt.Sprintf(getRaceQueries()[racesNext], query)
+		args = append(args, 0)
+	} else if filter.NthUpcoming > 0 {
+		query = fmt.Sprintf(getRaceQueries()[racesNext], query)
+		args = append(args, filter.NthUpcoming-1)
+	}
+
+	// Ranking comes last, so races are ranked among only those returned.
+	if filter.IncludeRank {
+		query = fmt.Sprintf(getRaceQueries()[racesRank], query)
+	}
+
+	// The count's placeholder precedes those of the wrapped query.
+	if filter.IncludeMeetingCounts {
+		query = fmt.Sprintf(getRaceQueries()[racesMeetingCounts], query)
+		args = append([]interface{}{now.Format(time.RFC3339)}, args...)
+	}
+
+	return query, args
+}
+
+// listArgs returns the arguments bound to the columns selected by the list query, ahead of those
+// of any filter, with statuses evaluated as at now.
+func (r *racesRepo) listArgs(now time.Time) []interface{} {
+	return []interface{}{now.Format(time.RFC3339), r.bettingClose(now)}
+}
+
+// bettingClose returns the argument bound to the betting open expression as at now, being the
+// earliest start time of a race still accepting bets.
+func (r *racesRepo) bettingClose(now time.Time) string {
+	return now.Add(r.bettingCutoff).Format(time.RFC3339)
+}
+
+// holidayClause returns the clause matching races starting on one of the holidays when
+// onHoliday is set, or on any other day otherwise, along with its args. Dates are those in the
+// repository's location, so each holiday is matched as the interval it spans there.
+func (r *racesRepo) holidayClause(onHoliday bool) (string, []interface{}) {
+	if len(r.holidays) == 0 {
+		if onHoliday {
+			return "0", nil
+		}
+
+		return "1", nil
+	}
+
+	location := r.location
+	if location == nil {
+		location = time.Local
+	}
+
+	var (
+		intervals []string
+		args      []interface{}
+	)
+
+	for _, holiday := range r.holidays {
+		// Holidays are validated as they're loaded.
+		start, err := time.ParseInLocation(holidayLayout, holiday, location)
+		if err != nil {
+			continue
+		}
+
+		intervals = append(intervals, "(datetime(races.advertised_start_time) >= datetime(?) AND datetime(races.advertised_start_time) < datetime(?))")
+		args = append(args, start.Format(time.RFC3339), start.AddDate(0, 0, 1).Format(time.RFC3339))
+	}
+
+	clause := "(" + strings.Join(intervals, " OR ") + ")"
+	if !onHoliday {
+		clause = "NOT " + clause
+	}
+
+	return clause, args
+}
+
+// applyOrder orders the races by the column requested by the filter, by default their advertised
+// start time, in the direction requested or ascending by default. Races without a start time
+// sort last when ordered by it.
+//
+// Races of a single meeting are instead naturally ordered by their number, unless the filter
+// asks for a column or direction, while races after an ID are always ordered by ID. Races are
+// ordered by how close they start to a reference time, nearest first, when given one. Sort
+// fields override all of these, other than ordering by ID, and a sort preset overrides
+// everything.
+func (r *racesRepo) applyOrder(query string, args []interface{}, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
+	if preset, ok := sortPresets[filter.GetSortPreset()]; ok {
+		return query + " ORDER BY " + preset, args
+	}
+
+	// Pulling races after an ID is a cursor over their insertion order.
+	if filter.GetIdAfter() > 0 {
+		return query + " ORDER BY id ASC", args
+	}
+
+	if len(filter.GetSortBy()) > 0 {
+		return query + " ORDER BY " + sortTerms(filter.SortBy), args
+	}
+
+	// Unknown directions fall back to ascending, rather than being rejected.
+	direction, err := order.ParseDirection(filter.GetOrderDirection())
+	if err != nil {
+		direction = order.Asc
+	}
+
+	if filter.GetReferenceTime() != nil {
+		return query + " ORDER BY CASE WHEN advertised_start_time IS NULL THEN 1 ELSE 0 END, ABS(julianday(advertised_start_time) - julianday(?)) " + direction + ", id",
+			append(args, filter.ReferenceTime.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.GetOrderDirection() == "" && filter.GetOrderBy() == "" && len(filter.GetMeetingIds()) == 1 {
+		return query + " ORDER BY number ASC", args
+	}
+
+	// Unknown columns fall back to ordering by start time, rather than being rejected.
+	if column, ok := orderColumns[strings.ToLower(filter.GetOrderBy())]; ok {
+		return query + " ORDER BY " + column + " " + direction, args
+	}
+
+	return query + " ORDER BY CASE WHEN advertised_start_time IS NULL THEN 1 ELSE 0 END, datetime(advertised_start_time) " + direction, args
+}
+
+// sortTerms returns the ORDER BY terms sorting races by each field in its direction, followed
+// by their ID so that pages never overlap. Fields and directions that aren't allowlisted are
+// skipped, and sorted ascending, respectively.
+func sortTerms(orders []*racing.OrderBy) string {
+	var terms []string
+
+	for _, by := range orders {
+		column, ok := sortColumns[by.GetField()]
+		if !ok {
+			continue
+		}
+
+		direction, err := order.ParseDirection(by.GetDirection().DirectionName())
+		if err != nil {
+			direction = order.Asc
+		}
+
+		terms = append(terms, column+" "+direction)
+	}
+
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+// applyPage limits the ordered races to the page requested by the filter. Races aren't limited
+// unless the filter has a limit, so an offset alone skips races without limiting the rest.
+func (r *racesRepo) applyPage(query string, args []interface{}, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
+	if filter.GetLimit() <= 0 && filter.GetOffset() <= 0 {
+		return query, args
+	}
+
+	limit := filter.GetLimit()
+	if limit <= 0 {
+		// SQLite only accepts an offset along with a limit, where a negative limit is no limit.
+		limit = -1
+	}
+
+	return query + " LIMIT ? OFFSET ?", append(args, limit, filter.GetOffset())
+}
+
+// scanRaces scans the races selected by a list query, formatting their local start time in the
+// given location, or by default in the timezone of their meeting, falling back to the
+// repository's location if set. Otherwise, the local start time is left empty.
+func (m *racesRepo) scanRaces(
+	rows *sql.Rows,
+	location *time.Location,
+) ([]*racing.Race, error) {
+	defer rows.Close()
+
+	var (
+		races     []*racing.Race
+		locations = make(map[string]*time.Location)
+	)
+
+	for rows.Next() {
+		var race racing.Race
+		var number sql.NullInt64
+		var startTime sql.NullString
+		var bettingOpen sql.NullBool
+
+		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &number, &race.Visible, &startTime, &race.Status, &bettingOpen, &race.Rank, &race.MeetingTimezone, &race.MeetingOpenRaceCount); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		// Races imported without a number are left numbered 0, while betting on races SQLite can't
+		// parse the start time of is left closed.
+		race.Number = number.Int64
+		race.BettingOpen = bettingOpen.Bool
+
+		if startTime.Valid {
+			advertisedStart, err := m.parseStartTime(race.Id, startTime.String)
+			if err != nil {
+				return nil, err
+			}
+
+			ts, err := ptypes.TimestampProto(advertisedStart)
+			if err != nil {
+				return nil, err
+			}
+
+			race.AdvertisedStartTime = ts
+
+			local := location
+			if local == nil {
+				local = meetingLocation(locations, race.MeetingTimezone)
+			}
+			if local == nil {
+				local = m.location
+			}
+
+			if local != nil {
+				race.LocalStartTime = advertisedStart.In(local).Format(time.RFC3339)
+			}
+		}
+
+		races = append(races, &race)
+	}
+
+	// Errors evaluating the query, such as of a malformed name_regexp, end the rows early.
+	return races, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it's matched literally using a backslash
+// escape character.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// statusTime returns the instant race statuses are evaluated at, being the as_of time of the
+// filter when set, otherwise now. It's in UTC, as are the start times it's compared with once
+// parsed by SQLite, so statuses never depend on any timezone.
+func statusTime(filter *racing.ListRacesRequestFilter) time.Time {
+	if filter.GetAsOf() == nil {
+		return time.Now().UTC()
+	}
+
+	return filter.AsOf.AsTime()
+}
+
+// closedGrace returns how long after closing races are listed along with the open races.
+func closedGrace(filter *racing.ListRacesRequestFilter) time.Duration {
+	if filter.GetClosedGraceSeconds() <= 0 {
+		return 0
+	}
+
+	return time.Duration(filter.ClosedGraceSeconds) * time.Second
+}
+
+// localTime returns the location of the timezone local start times are formatted in, or nil if
+// the filter doesn't ask for them.
+func localTime(filter *racing.ListRacesRequestFilter) (*time.Location, error) {
+	if filter.GetTimezone() == "" {
+		return nil, nil
+	}
+
+	return time.LoadLocation(filter.Timezone)
+}
+
+// meetingLocation returns the location of a meeting timezone, or nil if it isn't known, loading
+// each timezone into locations at most once.
+func meetingLocation(locations map[string]*time.Location, timezone string) *time.Location {
+	if timezone == "" {
+		return nil
+	}
+
+	location, ok := locations[timezone]
+	if !ok {
+		// An unknown timezone is remembered as nil, so it isn't loaded again.
+		location, _ = time.LoadLocation(timezone)
+		locations[timezone] = location
+	}
+
+	return location
+}
+
+// containsStatus reports whether status is one of statuses.
+func containsStatus(statuses []racing.RaceStatus, status racing.RaceStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
 	}
 
-	return races, nil
+	return false
 }
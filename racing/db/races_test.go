@@ -0,0 +1,2493 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestRepo returns a races repository over a new database in a temporary directory, with
+// its schema migrated and meetings seeded, holding just the given races.
+func newTestRepo(tb testing.TB, races []*racing.Race, opts ...RacesRepoOption) (RacesRepo, *sql.DB) {
+	tb.Helper()
+
+	racingDB := dbtest.Open(tb, DriverName)
+
+	return initTestRepo(tb, racingDB, races, opts...), racingDB
+}
+
+// initTestRepo returns a races repository over the database, with its schema migrated and
+// meetings seeded, holding just the given races.
+func initTestRepo(tb testing.TB, racingDB *sql.DB, races []*racing.Race, opts ...RacesRepoOption) RacesRepo {
+	tb.Helper()
+
+	repo := NewRacesRepo(racingDB, nil, opts...)
+	dbtest.Load(tb, racingDB, repo, races...)
+
+	return repo
+}
+
+// raceIDs returns the IDs of the races, in order.
+func raceIDs(races []*racing.Race) []int64 {
+	ids := make([]int64, 0, len(races))
+	for _, race := range races {
+		ids = append(ids, race.Id)
+	}
+
+	return ids
+}
+
+// countRaces returns how many races are in the database.
+func countRaces(tb testing.TB, racingDB *sql.DB) int {
+	tb.Helper()
+
+	var count int
+	if err := racingDB.QueryRow(`SELECT COUNT(*) FROM races`).Scan(&count); err != nil {
+		tb.Fatal(err)
+	}
+
+	return count
+}
+
+func TestInsertBatchRollsBackOnFailure(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{dbtest.NewRace(t, 1, 1, 1, start)})
+
+	// The third race has the ID of the first, so the batch fails part way through.
+	err := repo.InsertBatch(context.Background(), []*racing.Race{
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+		dbtest.NewRace(t, 1, 1, 4, start),
+		dbtest.NewRace(t, 4, 1, 5, start),
+	})
+	if err == nil {
+		t.Fatal("InsertBatch() with a duplicate ID succeeded")
+	}
+
+	if count := countRaces(t, racingDB); count != 1 {
+		t.Errorf("races after failed InsertBatch() = %d, want 1", count)
+	}
+
+	race, err := repo.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if race.Number != 1 {
+		t.Errorf("race 1 number after failed InsertBatch() = %d, want 1", race.Number)
+	}
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	const size = 1000
+
+	races := make([]*racing.Race, size)
+	for i := range races {
+		races[i] = dbtest.NewRace(b, 0, int64(i%10+1), int64(i%12+1), time.Now().Add(time.Duration(i)*time.Minute))
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		repo, _ := newTestRepo(b, nil)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if err := repo.InsertBatch(context.Background(), races); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// Inserting the races one at a time commits a transaction per race.
+	b.Run("single", func(b *testing.B) {
+		repo, _ := newTestRepo(b, nil)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			for _, race := range races {
+				if err := repo.InsertBatch(context.Background(), []*racing.Race{race}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// listIDs returns the sorted IDs of the races listed by the filter.
+func listIDs(tb testing.TB, repo RacesRepo, filter *racing.ListRacesRequestFilter) []int64 {
+	tb.Helper()
+
+	races, err := repo.List(context.Background(), filter)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	ids := raceIDs(races)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func TestListFirstRacePerMeeting(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 3, start),
+		dbtest.NewRace(t, 2, 1, 1, start.Add(time.Hour)),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(-time.Minute)),
+		dbtest.NewRace(t, 4, 2, 5, start),
+		dbtest.NewRace(t, 5, 2, 4, start.Add(time.Hour)),
+		dbtest.NewRace(t, 6, 3, 7, start),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{
+			name:   "every meeting",
+			filter: &racing.ListRacesRequestFilter{FirstRacePerMeeting: true},
+			want:   []int64{2, 5, 6},
+		},
+		{
+			name:   "filtered meetings",
+			filter: &racing.ListRacesRequestFilter{FirstRacePerMeeting: true, MeetingIds: []int64{1, 2}},
+			want:   []int64{2, 5},
+		},
+		{
+			name:   "lowest number matching the filter",
+			filter: &racing.ListRacesRequestFilter{FirstRacePerMeeting: true, Ids: []int64{1, 3, 4}},
+			want:   []int64{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListStatusesComposeWithFilter(t *testing.T) {
+	now := time.Now()
+	hidden := func(race *racing.Race) *racing.Race {
+		race.Visible = false
+		return race
+	}
+
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Hour)),
+		hidden(dbtest.NewRace(t, 4, 1, 4, now.Add(time.Hour))),
+		dbtest.NewRace(t, 5, 2, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 6, 3, 1, now.Add(time.Hour)),
+		hidden(dbtest.NewRace(t, 7, 2, 2, now.Add(time.Hour))),
+		dbtest.NewRace(t, 8, 1, 5, now.Add(-time.Minute)),
+		hidden(dbtest.NewRace(t, 9, 1, 6, now.Add(-time.Minute))),
+		dbtest.NewRace(t, 10, 3, 2, now.Add(-time.Minute)),
+	})
+
+	for _, id := range []int64{3, 7} {
+		if _, err := repo.Cancel(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{
+			name: "any of the statuses",
+			filter: &racing.ListRacesRequestFilter{
+				Statuses:    []racing.RaceStatus{racing.RaceStatus_OPEN, racing.RaceStatus_CANCELLED},
+				VisibleOnly: true,
+				MeetingIds:  []int64{1, 2},
+			},
+			want: []int64{1, 3, 5},
+		},
+		{
+			name: "closed",
+			filter: &racing.ListRacesRequestFilter{
+				Statuses:    []racing.RaceStatus{racing.RaceStatus_CLOSED},
+				VisibleOnly: true,
+				MeetingIds:  []int64{1, 2},
+			},
+			want: []int64{2, 8},
+		},
+		{
+			// The races closed within the grace window are an alternative to the open races, so
+			// they must still be visible and in the meetings.
+			name: "open with a closed grace window",
+			filter: &racing.ListRacesRequestFilter{
+				Statuses:           []racing.RaceStatus{racing.RaceStatus_OPEN},
+				ClosedGraceSeconds: 300,
+				VisibleOnly:        true,
+				MeetingIds:         []int64{1, 2},
+			},
+			want: []int64{1, 5, 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFilterGroupsStatuses(t *testing.T) {
+	repo := &racesRepo{}
+
+	query, _ := repo.applyFilter("", &racing.ListRacesRequestFilter{
+		Statuses:           []racing.RaceStatus{racing.RaceStatus_OPEN},
+		ClosedGraceSeconds: 300,
+		VisibleOnly:        true,
+		MeetingIds:         []int64{1, 2},
+	}, time.Now())
+
+	// The alternatives are parenthesised, so the OR between them doesn't escape the AND of the
+	// other clauses.
+	want := " AND (" + raceStatusExpression + " IN (?) OR (races.cancelled = 0 AND "
+	if !strings.Contains(query, want) {
+		t.Errorf("applyFilter() = %q, want it to contain %q", query, want)
+	}
+
+	if !strings.HasPrefix(query, " WHERE races.purged = 0 AND races.meeting_id IN (?,?) AND races.visible = 1 AND ") {
+		t.Errorf("applyFilter() = %q, want the meeting and visibility clauses AND-ed first", query)
+	}
+}
+
+func TestCancelledStatusOverridesStartTime(t *testing.T) {
+	now := time.Now()
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Hour)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(-time.Hour)),
+	})
+
+	for _, id := range []int64{3, 4} {
+		if _, err := repo.Cancel(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id   int64
+		want racing.RaceStatus
+	}{
+		{id: 1, want: racing.RaceStatus_OPEN},
+		{id: 2, want: racing.RaceStatus_CLOSED},
+		{id: 3, want: racing.RaceStatus_CANCELLED},
+		{id: 4, want: racing.RaceStatus_CANCELLED},
+	}
+
+	races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{IncludeCancelled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(map[int64]racing.RaceStatus)
+	for _, race := range races {
+		listed[race.Id] = race.Status
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.id), func(t *testing.T) {
+			race, err := repo.Get(context.Background(), tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if race.Status != tt.want {
+				t.Errorf("Get(%d) status = %v, want %v", tt.id, race.Status, tt.want)
+			}
+
+			if listed[tt.id] != tt.want {
+				t.Errorf("List() status of race %d = %v, want %v", tt.id, listed[tt.id], tt.want)
+			}
+		})
+	}
+}
+
+// homepageFilter is the filter of the homepage's races: visible open races of visible meetings.
+var homepageFilter = &racing.ListRacesRequestFilter{
+	VisibleOnly:     true,
+	ShowClosed:      &wrappers.BoolValue{Value: false},
+	InActiveMeeting: true,
+}
+
+// hideMeetings hides the meetings with the given IDs, and shows every other meeting.
+func hideMeetings(tb testing.TB, racingDB *sql.DB, ids ...int64) {
+	tb.Helper()
+
+	if _, err := racingDB.Exec(`UPDATE meetings SET visible = 1`); err != nil {
+		tb.Fatal(err)
+	}
+
+	for _, id := range ids {
+		if _, err := racingDB.Exec(`UPDATE meetings SET visible = 0 WHERE id = ?`, id); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestListHomepageRaces(t *testing.T) {
+	now := time.Now()
+	hidden := dbtest.NewRace(t, 3, 1, 3, now.Add(time.Hour))
+	hidden.Visible = false
+
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(-time.Hour)),
+		hidden,
+		dbtest.NewRace(t, 4, 2, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 5, 3, 1, now.Add(2*time.Hour)),
+		dbtest.NewRace(t, 6, 3, 2, now.Add(3*time.Hour)),
+	})
+	hideMeetings(t, racingDB, 2)
+
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "homepage", filter: homepageFilter, want: []int64{1, 5}},
+		{name: "closed shown", filter: &racing.ListRacesRequestFilter{ShowClosed: &wrappers.BoolValue{Value: true}}, want: []int64{1, 2, 3, 4, 5}},
+		{name: "closed hidden", filter: &racing.ListRacesRequestFilter{ShowClosed: &wrappers.BoolValue{Value: false}}, want: []int64{1, 3, 4, 5}},
+		{name: "in active meeting", filter: &racing.ListRacesRequestFilter{InActiveMeeting: true}, want: []int64{1, 2, 3, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHomepageQueryUsesIndexes(t *testing.T) {
+	repo, racingDB := newTestRepo(t, nil)
+	r := repo.(*racesRepo)
+
+	query, args := r.applyFilter(getRaceQueries()[racesList], homepageFilter, time.Now())
+	query, args = r.applyOrder(query, args, homepageFilter)
+	query, args = r.applyPage(query, args, homepageFilter)
+
+	rows, err := racingDB.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var plan []string
+
+	for rows.Next() {
+		var (
+			id, parent, unused int
+			detail             string
+		)
+
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+
+		plan = append(plan, detail)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var usesIndex bool
+
+	for _, detail := range plan {
+		// Older versions of SQLite describe scans as of a TABLE.
+		if strings.HasPrefix(detail, "SCAN races") || strings.HasPrefix(detail, "SCAN TABLE races") {
+			t.Errorf("query plan scans races: %q", detail)
+		}
+
+		if strings.Contains(detail, "INDEX races_visible_start") {
+			usesIndex = true
+		}
+	}
+
+	if !usesIndex {
+		t.Errorf("query plan %q doesn't use the races_visible_start index", plan)
+	}
+}
+
+func BenchmarkHomepageQuery(b *testing.B) {
+	const (
+		size     = 20000
+		meetings = 10
+	)
+
+	// Races start over the day before and after now, so about half are still open.
+	now := time.Now()
+	races := make([]*racing.Race, size)
+	for i := range races {
+		races[i] = dbtest.NewRace(b, int64(i+1), int64(i%meetings+1), int64(i%12+1), now.Add(time.Duration(i-size/2)*8*time.Second))
+		races[i].Visible = i%3 != 0
+	}
+
+	repo, racingDB := newTestRepo(b, races)
+	hideMeetings(b, racingDB, 2, 5)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.List(context.Background(), homepageFilter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestListNameRegexp(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+	}
+	races[0].Name, races[1].Name, races[2].Name = "Melbourne Cup", "Caulfield Cup", "Cox Plate"
+
+	repo, _ := newTestRepo(t, races)
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []int64
+		wantErr bool
+	}{
+		{name: "anywhere in the name", pattern: "Cup", want: []int64{1, 2}},
+		{name: "anchored", pattern: "^C", want: []int64{2, 3}},
+		{name: "alternatives", pattern: "^(Melbourne|Cox) ", want: []int64{1, 3}},
+		{name: "case-insensitive", pattern: "(?i)^cox plate$", want: []int64{3}},
+		{name: "no match", pattern: "Derby", want: []int64{}},
+		{name: "malformed", pattern: "Cup(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{NameRegexp: tt.pattern})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("List() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			got := raceIDs(listed)
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// listOrderedIDs returns the IDs of the races listed by the filter, in the order listed.
+func listOrderedIDs(tb testing.TB, repo RacesRepo, filter *racing.ListRacesRequestFilter) []int64 {
+	tb.Helper()
+
+	races, err := repo.List(context.Background(), filter)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return raceIDs(races)
+}
+
+func TestListOrderBy(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+
+	// Each column orders the races differently.
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 2, 3, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 2, 3, 1, start),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(time.Hour)),
+	}
+	races[0].Name, races[1].Name, races[2].Name = "Bravo", "Charlie", "Alpha"
+
+	repo, _ := newTestRepo(t, races)
+
+	tests := []struct {
+		name      string
+		orderBy   string
+		direction string
+		want      []int64
+	}{
+		{name: "advertised_start_time", orderBy: "advertised_start_time", want: []int64{2, 3, 1}},
+		{name: "name", orderBy: "name", want: []int64{3, 1, 2}},
+		{name: "meeting_id", orderBy: "meeting_id", want: []int64{3, 1, 2}},
+		{name: "number", orderBy: "number", want: []int64{2, 3, 1}},
+		{name: "descending", orderBy: "name", direction: "DESC", want: []int64{2, 1, 3}},
+		{name: "case-insensitive direction", orderBy: "number", direction: "desc", want: []int64{1, 3, 2}},
+		{name: "unknown field falls back to start time", orderBy: "id; DROP TABLE races", want: []int64{2, 3, 1}},
+		{name: "unknown direction falls back to ascending", orderBy: "name", direction: "UP", want: []int64{3, 1, 2}},
+		{name: "both unknown", orderBy: "venue", direction: "sideways", want: []int64{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{OrderBy: tt.orderBy, OrderDirection: tt.direction}
+
+			if got := listOrderedIDs(t, repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictTimestamps(t *testing.T) {
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name       string
+		opts       []RacesRepoOption
+		stored     string
+		wantInit   bool
+		wantList   bool
+		wantParsed time.Time
+	}{
+		{name: "strict accepts RFC3339", opts: []RacesRepoOption{WithStrictTimestamps()}, stored: start.Format(time.RFC3339), wantInit: true, wantList: true, wantParsed: start},
+		{name: "strict rejects others", opts: []RacesRepoOption{WithStrictTimestamps()}, stored: start.Format("2006-01-02 15:04:05")},
+		{name: "lenient accepts others", stored: start.Format("2006-01-02 15:04:05"), wantInit: true, wantList: true, wantParsed: start},
+		{name: "lenient rejects the unparseable", stored: "next Tuesday", wantInit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, racingDB := newTestRepo(t, []*racing.Race{dbtest.NewRace(t, 7, 1, 1, start)}, tt.opts...)
+
+			if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = ?`, tt.stored); err != nil {
+				t.Fatal(err)
+			}
+
+			// Races are checked as they're read, such as those stored after startup.
+			races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{})
+			if (err == nil) != tt.wantList {
+				t.Fatalf("List() error = %v, want success %t", err, tt.wantList)
+			}
+			if err != nil && (!strings.Contains(err.Error(), "race 7") || !strings.Contains(err.Error(), tt.stored)) {
+				t.Errorf("List() error = %v, want the offending race and start time named", err)
+			}
+			if err == nil && (len(races) != 1 || !races[0].AdvertisedStartTime.AsTime().Equal(tt.wantParsed)) {
+				t.Errorf("List() = %v, want race 7 starting at %s", races, tt.wantParsed)
+			}
+
+			// Repositories check every stored start time as they're initialised too.
+			err = NewRacesRepo(racingDB, nil, tt.opts...).Init()
+			if (err == nil) != tt.wantInit {
+				t.Fatalf("Init() error = %v, want success %t", err, tt.wantInit)
+			}
+			if err != nil && !strings.Contains(err.Error(), "race 7") {
+				t.Errorf("Init() error = %v, want the offending race named", err)
+			}
+		})
+	}
+}
+
+func BenchmarkListNameRegexp(b *testing.B) {
+	races := make([]*racing.Race, 1000)
+	for i := range races {
+		races[i] = dbtest.NewRace(b, int64(i+1), int64(i%10+1), int64(i%12+1), time.Now().Add(time.Hour))
+	}
+
+	repo, _ := newTestRepo(b, races)
+	filter := &racing.ListRacesRequestFilter{NameRegexp: `^Race \d*7$`}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.List(context.Background(), filter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// hiddenRace returns the race, hidden.
+func hiddenRace(race *racing.Race) *racing.Race {
+	race.Visible = false
+	return race
+}
+
+func TestListVisibleInHiddenMeeting(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		hiddenRace(dbtest.NewRace(t, 3, 2, 2, start)),
+		hiddenRace(dbtest.NewRace(t, 4, 1, 2, start)),
+	})
+	hideMeetings(t, racingDB, 2)
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "report", filter: &racing.ListRacesRequestFilter{VisibleInHiddenMeeting: true}, want: []int64{2}},
+		{name: "not reporting", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListIDsMatchesListOrder(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 2, 3, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 2, 3, 1, start),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(time.Hour)),
+		dbtest.NewRace(t, 4, 1, 1, start.Add(3*time.Hour)),
+		hiddenRace(dbtest.NewRace(t, 5, 2, 2, start.Add(30*time.Minute))),
+	}
+	races[0].Name, races[1].Name, races[2].Name, races[3].Name, races[4].Name = "Delta", "Bravo", "Echo", "Alpha", "Charlie"
+
+	repo, _ := newTestRepo(t, races)
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+	}{
+		{name: "default", filter: &racing.ListRacesRequestFilter{}},
+		{name: "descending", filter: &racing.ListRacesRequestFilter{OrderDirection: "DESC"}},
+		{name: "by name", filter: &racing.ListRacesRequestFilter{OrderBy: "name"}},
+		{name: "filtered", filter: &racing.ListRacesRequestFilter{VisibleOnly: true, MeetingIds: []int64{1, 2}}},
+		{name: "paged", filter: &racing.ListRacesRequestFilter{Limit: 2, Offset: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := repo.ListIDs(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := listOrderedIDs(t, repo, tt.filter); !reflect.DeepEqual(ids, want) {
+				t.Errorf("ListIDs() = %v, want %v as listed", ids, want)
+			}
+		})
+	}
+}
+
+func TestListOrdersNullStartsLast(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start.Add(2*time.Hour)),
+	})
+
+	if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "ascending", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 3, 2}},
+		{name: "descending", filter: &racing.ListRacesRequestFilter{OrderDirection: "DESC"}, want: []int64{3, 1, 2}},
+		{
+			name:   "sort by ascending",
+			filter: &racing.ListRacesRequestFilter{SortBy: []*racing.OrderBy{{Field: racing.OrderField_START_TIME, Direction: racing.OrderDirection_ASC}}},
+			want:   []int64{1, 3, 2},
+		},
+		{
+			name:   "sort by descending",
+			filter: &racing.ListRacesRequestFilter{SortBy: []*racing.OrderBy{{Field: racing.OrderField_START_TIME, Direction: racing.OrderDirection_DESC}}},
+			want:   []int64{3, 1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listOrderedIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListIncludeCancelled(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, dbtest.NewRaces(t, start, start, start))
+
+	if _, err := repo.Cancel(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "hidden by default", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 3}},
+		{name: "included", filter: &racing.ListRacesRequestFilter{IncludeCancelled: true}, want: []int64{1, 2, 3}},
+		{name: "implied by the cancelled status", filter: &racing.ListRacesRequestFilter{Statuses: []racing.RaceStatus{racing.RaceStatus_CANCELLED}}, want: []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// An absent filter hides them too.
+	if got := listIDs(t, repo, nil); !reflect.DeepEqual(got, []int64{1, 3}) {
+		t.Errorf("List(nil) IDs = %v, want [1 3]", got)
+	}
+}
+
+func TestListMeetingScopedOrder(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+
+	// Within meeting 1, numbers run against start times.
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 3, start),
+		dbtest.NewRace(t, 2, 1, 1, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(time.Hour)),
+		dbtest.NewRace(t, 4, 2, 1, start.Add(30*time.Minute)),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "single meeting by number", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}}, want: []int64{2, 3, 1}},
+		{name: "several meetings by start time", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}}, want: []int64{1, 4, 3, 2}},
+		{name: "single meeting with a direction", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}, OrderDirection: "ASC"}, want: []int64{1, 3, 2}},
+		{name: "single meeting with an order", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}, OrderBy: "advertised_start_time"}, want: []int64{1, 3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listOrderedIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPerMeetingLimit(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, start.Add(3*time.Hour)),
+		dbtest.NewRace(t, 4, 1, 4, start.Add(10*time.Minute)),
+		dbtest.NewRace(t, 5, 2, 1, start.Add(30*time.Minute)),
+		dbtest.NewRace(t, 6, 2, 2, start.Add(90*time.Minute)),
+		dbtest.NewRace(t, 7, 2, 3, start.Add(15*time.Minute)),
+		dbtest.NewRace(t, 8, 3, 1, start.Add(5*time.Hour)),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "soonest of each meeting", filter: &racing.ListRacesRequestFilter{PerMeetingLimit: 2}, want: []int64{4, 7, 5, 1, 8}},
+		{name: "bounded overall", filter: &racing.ListRacesRequestFilter{PerMeetingLimit: 2, Limit: 3}, want: []int64{4, 7, 5}},
+		{name: "unlimited", filter: &racing.ListRacesRequestFilter{}, want: []int64{4, 7, 5, 1, 6, 2, 3, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listOrderedIDs(t, repo, tt.filter)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFutureAsOf(t *testing.T) {
+	now := time.Now()
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(3*time.Hour)),
+	})
+
+	// Race 1 is open now, but will have started by then.
+	asOf, _ := ptypes.TimestampProto(now.Add(2 * time.Hour))
+
+	races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[int64]racing.RaceStatus)
+	for _, race := range races {
+		got[race.Id] = race.Status
+	}
+
+	if want := map[int64]racing.RaceStatus{1: racing.RaceStatus_CLOSED, 2: racing.RaceStatus_OPEN}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() statuses = %v, want %v", got, want)
+	}
+
+	// The statuses are filtered as at then too.
+	open := &racing.ListRacesRequestFilter{AsOf: asOf, Statuses: []racing.RaceStatus{racing.RaceStatus_OPEN}}
+	if got := listIDs(t, repo, open); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("List() open IDs = %v, want [2]", got)
+	}
+}
+
+func TestListIDAfterPullsInBatches(t *testing.T) {
+	// Races start in the reverse of their insertion order, so the pull can't follow start times.
+	start := time.Now().Add(time.Hour)
+	var races []*racing.Race
+	for id := int64(1); id <= 7; id++ {
+		races = append(races, dbtest.NewRace(t, id, 1, id, start.Add(-time.Duration(id)*time.Minute)))
+	}
+
+	repo, _ := newTestRepo(t, races)
+
+	pull := func(after int64) []int64 {
+		return listOrderedIDs(t, repo, &racing.ListRacesRequestFilter{IdAfter: after, Limit: 3})
+	}
+
+	// Every race after the last seen is pulled once, in insertion order.
+	var pulled []int64
+	for after, batches := int64(2), 0; ; batches++ {
+		batch := pull(after)
+		if len(batch) == 0 {
+			break
+		}
+
+		if batches > 3 {
+			t.Fatalf("pulled %v and still pulling", pulled)
+		}
+
+		pulled = append(pulled, batch...)
+		after = batch[len(batch)-1]
+	}
+
+	if want := []int64{3, 4, 5, 6, 7}; !reflect.DeepEqual(pulled, want) {
+		t.Errorf("pulled IDs = %v, want %v", pulled, want)
+	}
+
+	// Races added since are pulled next.
+	if err := repo.InsertBatch(context.Background(), []*racing.Race{dbtest.NewRace(t, 8, 1, 8, start.Add(-time.Hour))}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pull(7); !reflect.DeepEqual(got, []int64{8}) {
+		t.Errorf("IDs after 7 once race 8 added = %v, want [8]", got)
+	}
+}
+
+func TestListMeetingVisibility(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		hiddenRace(dbtest.NewRace(t, 2, 1, 2, start)),
+		dbtest.NewRace(t, 3, 2, 1, start),
+		hiddenRace(dbtest.NewRace(t, 4, 2, 2, start)),
+	})
+	hideMeetings(t, racingDB, 2)
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "visible meetings", filter: &racing.ListRacesRequestFilter{MeetingVisibility: &wrappers.BoolValue{Value: true}}, want: []int64{1, 2}},
+		{name: "hidden meetings", filter: &racing.ListRacesRequestFilter{MeetingVisibility: &wrappers.BoolValue{Value: false}}, want: []int64{3, 4}},
+		{name: "unset", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 2, 3, 4}},
+		{name: "with visible only", filter: &racing.ListRacesRequestFilter{MeetingVisibility: &wrappers.BoolValue{Value: false}, VisibleOnly: true}, want: []int64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// nameMeetings renames the meetings with the given IDs.
+func nameMeetings(tb testing.TB, racingDB *sql.DB, names map[int64]string) {
+	tb.Helper()
+
+	for id, name := range names {
+		if _, err := racingDB.Exec(`UPDATE meetings SET name = ? WHERE id = ?`, name, id); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestListSearch(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		dbtest.NewRace(t, 3, 1, 2, start),
+	}
+	races[0].Name, races[1].Name, races[2].Name = "Melbourne Cup", "Cox Plate", "Valley Sprint"
+
+	repo, racingDB := newTestRepo(t, races)
+	nameMeetings(t, racingDB, map[int64]string{1: "Flemington", 2: "Moonee Valley"})
+
+	tests := []struct {
+		name   string
+		search string
+		want   []int64
+	}{
+		{name: "race name", search: "Cup", want: []int64{1}},
+		{name: "meeting name", search: "Flem", want: []int64{1, 3}},
+		{name: "either name", search: "Valley", want: []int64{2, 3}},
+		{name: "no match", search: "Derby", want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{Search: tt.search}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusSummaryMatchesCounts(t *testing.T) {
+	now := time.Now()
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(2*time.Hour)),
+		dbtest.NewRace(t, 3, 2, 1, now.Add(3*time.Hour)),
+		dbtest.NewRace(t, 4, 2, 2, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 5, 3, 1, now.Add(-2*time.Hour)),
+		dbtest.NewRace(t, 6, 3, 2, now.Add(time.Hour)),
+		dbtest.NewRace(t, 7, 3, 3, now.Add(-3*time.Hour)),
+	})
+
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.SetResult(context.Background(), 7, []*racing.Placing{{Position: 1, RunnerNumber: 4, RunnerName: "Winx"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	summary, err := repo.StatusSummary(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf, IncludeCancelled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status racing.RaceStatus
+		got    int64
+		want   int64
+	}{
+		{status: racing.RaceStatus_OPEN, got: summary.Open, want: 3},
+		{status: racing.RaceStatus_CLOSED, got: summary.Closed, want: 2},
+		{status: racing.RaceStatus_CANCELLED, got: summary.Cancelled, want: 1},
+		{status: racing.RaceStatus_SETTLED, got: summary.Settled, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			count, err := repo.Count(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf, Statuses: []racing.RaceStatus{tt.status}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.got != count || tt.got != tt.want {
+				t.Errorf("StatusSummary() %v = %d, want %d, as counted individually (%d)", tt.status, tt.got, tt.want, count)
+			}
+		})
+	}
+}
+
+func TestListOnHoliday(t *testing.T) {
+	brisbane, err := time.LoadLocation("Australia/Brisbane")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Races 3 and 4 start late in the evening, on a different UTC date to their local date.
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, time.Date(2030, 12, 25, 10, 0, 0, 0, brisbane)),
+		dbtest.NewRace(t, 2, 1, 2, time.Date(2030, 12, 26, 10, 0, 0, 0, brisbane)),
+		dbtest.NewRace(t, 3, 1, 3, time.Date(2030, 12, 24, 23, 30, 0, 0, brisbane)),
+		dbtest.NewRace(t, 4, 1, 4, time.Date(2030, 12, 25, 23, 30, 0, 0, brisbane)),
+	}
+
+	repo, racingDB := newTestRepo(t, races, WithHolidays([]string{"2030-12-25"}), WithLocation(brisbane))
+
+	tests := []struct {
+		name   string
+		repo   RacesRepo
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "on a holiday", repo: repo, filter: &racing.ListRacesRequestFilter{OnHoliday: &wrappers.BoolValue{Value: true}}, want: []int64{1, 4}},
+		{name: "on other days", repo: repo, filter: &racing.ListRacesRequestFilter{OnHoliday: &wrappers.BoolValue{Value: false}}, want: []int64{2, 3}},
+		{name: "unset", repo: repo, filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 2, 3, 4}},
+		{name: "without holidays", repo: NewRacesRepo(racingDB, nil), filter: &racing.ListRacesRequestFilter{OnHoliday: &wrappers.BoolValue{Value: true}}, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, tt.repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListStatusFilterMatchesDerivedStatus(t *testing.T) {
+	now := time.Now()
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Second)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(-time.Second)),
+		dbtest.NewRace(t, 5, 1, 5, now.Add(time.Hour)),
+		dbtest.NewRace(t, 6, 1, 6, now.Add(-2*time.Hour)),
+	})
+
+	if _, err := repo.Cancel(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.SetResult(context.Background(), 6, []*racing.Placing{{Position: 1, RunnerNumber: 1, RunnerName: "Winx"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Statuses are derived as at a few instants, including either side of races 3 and 4.
+	for _, offset := range []time.Duration{0, -time.Minute, 30 * time.Minute} {
+		asOf, _ := ptypes.TimestampProto(now.Add(offset))
+
+		for _, status := range []racing.RaceStatus{racing.RaceStatus_OPEN, racing.RaceStatus_CLOSED, racing.RaceStatus_CANCELLED, racing.RaceStatus_SETTLED} {
+			t.Run(fmt.Sprintf("%v as at %s", status, offset), func(t *testing.T) {
+				races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf, Statuses: []racing.RaceStatus{status}})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(races) == 0 {
+					t.Fatalf("List() listed no %v races", status)
+				}
+
+				for _, race := range races {
+					if race.Status != status {
+						t.Errorf("List() race %d status = %v, want %v", race.Id, race.Status, status)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestListLastRacePerMeeting(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 3, start),
+		dbtest.NewRace(t, 2, 1, 1, start.Add(time.Hour)),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(-time.Minute)),
+		dbtest.NewRace(t, 4, 2, 5, start),
+		dbtest.NewRace(t, 5, 2, 4, start.Add(time.Hour)),
+		dbtest.NewRace(t, 6, 3, 7, start),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{
+			name:   "every meeting",
+			filter: &racing.ListRacesRequestFilter{LastRacePerMeeting: true},
+			want:   []int64{2, 5, 6},
+		},
+		{
+			name:   "filtered meetings",
+			filter: &racing.ListRacesRequestFilter{LastRacePerMeeting: true, MeetingIds: []int64{1, 2}},
+			want:   []int64{2, 5},
+		},
+		{
+			name:   "latest matching the filter",
+			filter: &racing.ListRacesRequestFilter{LastRacePerMeeting: true, Ids: []int64{1, 3, 4}},
+			want:   []int64{1, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBettingOpenAroundCutoff(t *testing.T) {
+	const cutoff = 30 * time.Second
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{dbtest.NewRace(t, 1, 1, 1, start)}, WithBettingCutoff(cutoff))
+
+	// The clock is frozen at each instant by listing as at it.
+	tests := []struct {
+		name       string
+		at         time.Time
+		wantOpen   bool
+		wantStatus racing.RaceStatus
+	}{
+		{name: "before the cutoff", at: start.Add(-cutoff - time.Second), wantOpen: true, wantStatus: racing.RaceStatus_OPEN},
+		{name: "at the cutoff", at: start.Add(-cutoff), wantOpen: false, wantStatus: racing.RaceStatus_OPEN},
+		{name: "after the cutoff", at: start.Add(-cutoff + time.Second), wantOpen: false, wantStatus: racing.RaceStatus_OPEN},
+		{name: "after the start", at: start.Add(time.Second), wantOpen: false, wantStatus: racing.RaceStatus_CLOSED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf, _ := ptypes.TimestampProto(tt.at)
+
+			races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(races) != 1 || races[0].BettingOpen != tt.wantOpen || races[0].Status != tt.wantStatus {
+				t.Fatalf("List() = %v, want race 1 %v with betting open %t", races, tt.wantStatus, tt.wantOpen)
+			}
+
+			want := []int64{}
+			if tt.wantOpen {
+				want = []int64{1}
+			}
+
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{AsOf: asOf, BettingOpenOnly: true}); !reflect.DeepEqual(got, want) {
+				t.Errorf("List() betting open IDs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListNextOnly(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		races     []*racing.Race
+		cancelled []int64
+		want      []int64
+	}{
+		{
+			// Closer hidden, closed and cancelled races are passed over, as are later races.
+			name: "soonest upcoming race",
+			races: []*racing.Race{
+				hiddenRace(dbtest.NewRace(t, 1, 1, 1, now.Add(10*time.Minute))),
+				dbtest.NewRace(t, 2, 1, 2, now.Add(-time.Minute)),
+				dbtest.NewRace(t, 3, 1, 3, now.Add(20*time.Minute)),
+				dbtest.NewRace(t, 4, 2, 1, now.Add(30*time.Minute)),
+				dbtest.NewRace(t, 5, 3, 1, now.Add(time.Hour)),
+			},
+			cancelled: []int64{3},
+			want:      []int64{4},
+		},
+		{
+			name: "no upcoming races",
+			races: []*racing.Race{
+				dbtest.NewRace(t, 1, 1, 1, now.Add(-time.Hour)),
+				hiddenRace(dbtest.NewRace(t, 2, 1, 2, now.Add(time.Hour))),
+			},
+			want: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := newTestRepo(t, tt.races)
+
+			for _, id := range tt.cancelled {
+				if _, err := repo.Cancel(context.Background(), id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{NextOnly: true}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListNamePrefix(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	races := dbtest.NewRaces(t, start, start, start, start, start)
+	races[0].Name, races[1].Name, races[2].Name, races[3].Name, races[4].Name = "North Sydney", "Northern Star", "Far North", "north shore", "100% North"
+
+	repo, _ := newTestRepo(t, races)
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []int64
+	}{
+		{name: "prefix ignoring case", prefix: "North", want: []int64{1, 2, 4}},
+		{name: "whole word", prefix: "North ", want: []int64{1, 4}},
+		{name: "mid-string rejected", prefix: "Sydney", want: []int64{}},
+		{name: "wildcards matched literally", prefix: "%North", want: []int64{}},
+		{name: "literal wildcard", prefix: "100%", want: []int64{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{NamePrefix: tt.prefix}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListNumbers(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 3, start),
+		dbtest.NewRace(t, 2, 1, 1, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+		dbtest.NewRace(t, 4, 2, 5, start),
+		dbtest.NewRace(t, 5, 2, 2, start),
+		dbtest.NewRace(t, 6, 3, 7, start),
+	})
+
+	// Cancelled races' numbers aren't in use.
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		meetingIDs []int64
+		want       []int64
+	}{
+		{name: "every meeting", meetingIDs: nil, want: []int64{1, 2, 3, 5}},
+		{name: "one meeting", meetingIDs: []int64{1}, want: []int64{1, 3}},
+		{name: "several meetings", meetingIDs: []int64{2, 3}, want: []int64{2, 5}},
+		{name: "meeting without races", meetingIDs: []int64{4}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ListNumbers(context.Background(), tt.meetingIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("ListNumbers(%v) = %v, want %v", tt.meetingIDs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListIDsWithinMeetings(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 2, 1, start),
+		dbtest.NewRace(t, 4, 3, 1, start),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "IDs alone", filter: &racing.ListRacesRequestFilter{Ids: []int64{1, 3, 4}}, want: []int64{1, 3, 4}},
+		{name: "meetings alone", filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 2}}, want: []int64{1, 2, 3}},
+		{name: "intersected", filter: &racing.ListRacesRequestFilter{Ids: []int64{1, 3, 4}, MeetingIds: []int64{1, 2}}, want: []int64{1, 3}},
+		{name: "disjoint", filter: &racing.ListRacesRequestFilter{Ids: []int64{4}, MeetingIds: []int64{1}}, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartTimeClashes(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		dbtest.NewRace(t, 3, 1, 2, start.Add(time.Hour)),
+		dbtest.NewRace(t, 4, 1, 3, start.Add(time.Hour)),
+		dbtest.NewRace(t, 5, 3, 1, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 6, 4, 1, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 7, 3, 2, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 8, 4, 2, start.Add(3*time.Hour)),
+	})
+
+	clashes, err := repo.StartTimeClashes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Races 3 and 4 share a start time, but within the one meeting.
+	want := []struct {
+		start time.Time
+		ids   []int64
+	}{
+		{start: start, ids: []int64{1, 2}},
+		{start: start.Add(2 * time.Hour), ids: []int64{5, 6, 7}},
+	}
+
+	if len(clashes) != len(want) {
+		t.Fatalf("StartTimeClashes() = %v, want %d clashes", clashes, len(want))
+	}
+
+	for i, clash := range clashes {
+		if !clash.AdvertisedStartTime.AsTime().Equal(want[i].start) || !reflect.DeepEqual(clash.RaceIds, want[i].ids) {
+			t.Errorf("StartTimeClashes()[%d] = races %v at %s, want %v at %s", i, clash.RaceIds, clash.AdvertisedStartTime.AsTime(), want[i].ids, want[i].start)
+		}
+	}
+}
+
+func TestListNewestPreset(t *testing.T) {
+	// Races start in no particular relation to the order they were added in.
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 2, start.Add(time.Hour)),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		dbtest.NewRace(t, 3, 1, 1, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 4, 1, 3, start.Add(30*time.Minute)),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "every race", filter: &racing.ListRacesRequestFilter{SortPreset: racing.SortPreset_NEWEST}, want: []int64{4, 3, 2, 1}},
+		// The preset takes the place of ordering a single meeting by number.
+		{name: "within a meeting", filter: &racing.ListRacesRequestFilter{SortPreset: racing.SortPreset_NEWEST, MeetingIds: []int64{1}}, want: []int64{4, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listOrderedIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Races added later are newer, whatever their ID was to start with.
+	if err := repo.InsertBatch(context.Background(), []*racing.Race{dbtest.NewRace(t, 0, 1, 4, start)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listOrderedIDs(t, repo, &racing.ListRacesRequestFilter{SortPreset: racing.SortPreset_NEWEST, Limit: 1}); !reflect.DeepEqual(got, []int64{5}) {
+		t.Errorf("List() newest ID after adding a race = %v, want [5]", got)
+	}
+}
+
+func TestListClosedSince(t *testing.T) {
+	since := time.Now().Add(-time.Hour).Truncate(time.Second)
+	now := since.Add(30 * time.Minute)
+
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, since.Add(-time.Minute)),
+		dbtest.NewRace(t, 2, 1, 2, since),
+		dbtest.NewRace(t, 3, 1, 3, since.Add(time.Second)),
+		dbtest.NewRace(t, 4, 1, 4, since.Add(10*time.Minute)),
+		dbtest.NewRace(t, 5, 1, 5, now),
+		dbtest.NewRace(t, 6, 1, 6, now.Add(time.Second)),
+		dbtest.NewRace(t, 7, 1, 7, since.Add(20*time.Minute)),
+	})
+
+	// Cancelled races never close.
+	if _, err := repo.Cancel(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+
+	closedSince, _ := ptypes.TimestampProto(since)
+	asOf, _ := ptypes.TimestampProto(now)
+
+	got := listIDs(t, repo, &racing.ListRacesRequestFilter{ClosedSince: closedSince, AsOf: asOf, IncludeCancelled: true})
+	if want := []int64{3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() IDs closed since %s = %v, want %v", since, got, want)
+	}
+}
+
+func TestListMeetingNamePrefix(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		dbtest.NewRace(t, 3, 3, 1, start),
+		dbtest.NewRace(t, 4, 4, 1, start),
+		dbtest.NewRace(t, 5, 5, 1, start),
+	})
+	nameMeetings(t, racingDB, map[int64]string{1: "Flemington", 2: "Moonee Valley", 3: "Happy_Valley", 4: "HappyValley", 5: "100% Club"})
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []int64
+	}{
+		{name: "prefix", prefix: "Flem", want: []int64{1}},
+		{name: "ignoring case", prefix: "moonee", want: []int64{2}},
+		{name: "not a prefix", prefix: "Valley", want: []int64{}},
+		{name: "underscore is literal", prefix: "Happy_", want: []int64{3}},
+		{name: "percent is literal", prefix: "100%", want: []int64{5}},
+		{name: "percent matches nothing else", prefix: "Happy%", want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{MeetingNamePrefix: tt.prefix}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListIncludeRank(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start.Add(3*time.Minute)),
+		dbtest.NewRace(t, 2, 1, 2, start.Add(time.Minute)),
+		hiddenRace(dbtest.NewRace(t, 3, 2, 1, start.Add(2*time.Minute))),
+		dbtest.NewRace(t, 4, 2, 2, start),
+		dbtest.NewRace(t, 5, 3, 1, start.Add(4*time.Minute)),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "every race", filter: &racing.ListRacesRequestFilter{IncludeRank: true}, want: []int64{4, 2, 3, 1, 5}},
+		// Races are ranked among only those returned, leaving no gap for the hidden race.
+		{name: "visible only", filter: &racing.ListRacesRequestFilter{IncludeRank: true, VisibleOnly: true}, want: []int64{4, 2, 1, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			races, err := repo.List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := raceIDs(races); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("List() IDs = %v, want %v", got, tt.want)
+			}
+
+			for i, race := range races {
+				if want := int64(i + 1); race.Rank != want {
+					t.Errorf("race %d rank = %d, want %d", race.Id, race.Rank, want)
+				}
+			}
+		})
+	}
+
+	// Ranks are only populated when asked for.
+	races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, race := range races {
+		if race.Rank != 0 {
+			t.Errorf("race %d rank = %d without include_rank, want 0", race.Id, race.Rank)
+		}
+	}
+}
+
+func TestListVisibilityMismatch(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		hiddenRace(dbtest.NewRace(t, 2, 1, 2, start)),
+		dbtest.NewRace(t, 3, 2, 1, start),
+		hiddenRace(dbtest.NewRace(t, 4, 2, 2, start)),
+	})
+
+	// Meeting 1 is visible and meeting 2 hidden, so race 2 is hidden in a visible meeting and
+	// race 3 visible in a hidden one.
+	hideMeetings(t, racingDB, 2)
+
+	if got, want := listIDs(t, repo, &racing.ListRacesRequestFilter{VisibilityMismatch: true}), []int64{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() IDs = %v, want %v", got, want)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(15 * time.Minute)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start.Add(15*time.Minute-time.Second)),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 2, 1, start.Add(15*time.Minute)),
+		dbtest.NewRace(t, 4, 2, 2, start.Add(45*time.Minute)),
+		dbtest.NewRace(t, 5, 3, 1, start),
+	})
+
+	// Races without a start time are left out.
+	if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 5`); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets, err := repo.Timeline(context.Background(), &racing.ListRacesRequestFilter{}, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type bucket struct {
+		start time.Time
+		ids   []int64
+	}
+
+	// Intervals without races have no bucket.
+	want := []bucket{
+		{start: start, ids: []int64{1, 2}},
+		{start: start.Add(15 * time.Minute), ids: []int64{3}},
+		{start: start.Add(45 * time.Minute), ids: []int64{4}},
+	}
+
+	got := make([]bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Count != int64(len(b.RaceIds)) {
+			t.Errorf("bucket at %s count = %d, want %d", b.Start.AsTime(), b.Count, len(b.RaceIds))
+		}
+		got = append(got, bucket{start: b.Start.AsTime(), ids: b.RaceIds})
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Timeline() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].start.Equal(want[i].start) || !reflect.DeepEqual(got[i].ids, want[i].ids) {
+			t.Errorf("Timeline() bucket %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestListNamesCaseInsensitive(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+	}
+	races[0].Name, races[1].Name, races[2].Name = "Melbourne Cup", "MELBOURNE CUP", "Cox Plate"
+
+	repo, _ := newTestRepo(t, races)
+
+	tests := []struct {
+		name            string
+		names           []string
+		caseInsensitive bool
+		want            []int64
+	}{
+		{name: "exact case", names: []string{"Melbourne Cup"}, want: []int64{1}},
+		{name: "other case", names: []string{"melbourne cup"}, want: []int64{}},
+		{name: "ignoring case", names: []string{"melbourne cup"}, caseInsensitive: true, want: []int64{1, 2}},
+		{name: "several ignoring case", names: []string{"melbourne cup", "COX PLATE"}, caseInsensitive: true, want: []int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{Names: tt.names, NamesCaseInsensitive: tt.caseInsensitive}
+			if got := listIDs(t, repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	cutoff := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		opts []RacesRepoOption
+		// stored is the number of races left stored after purging.
+		stored int
+	}{
+		{name: "deleting", stored: 3},
+		{name: "soft", opts: []RacesRepoOption{WithSoftPurge()}, stored: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, racingDB := newTestRepo(t, []*racing.Race{
+				dbtest.NewRace(t, 1, 1, 1, cutoff.Add(-time.Hour)),
+				dbtest.NewRace(t, 2, 1, 2, cutoff.Add(-time.Second)),
+				dbtest.NewRace(t, 3, 1, 3, cutoff),
+				dbtest.NewRace(t, 4, 1, 4, cutoff.Add(time.Hour)),
+				dbtest.NewRace(t, 5, 1, 5, cutoff.Add(-time.Hour)),
+			}, tt.opts...)
+
+			// Races without a start time are never purged.
+			if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 5`); err != nil {
+				t.Fatal(err)
+			}
+
+			count, err := repo.Purge(context.Background(), cutoff, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("Purge() dry run count = %d, want 2", count)
+			}
+			if got, want := listIDs(t, repo, &racing.ListRacesRequestFilter{}), []int64{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+				t.Errorf("List() IDs after a dry run = %v, want %v", got, want)
+			}
+
+			purged, err := repo.Purge(context.Background(), cutoff, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != count {
+				t.Errorf("Purge() = %d, want the dry run's count of %d", purged, count)
+			}
+			if got, want := listIDs(t, repo, &racing.ListRacesRequestFilter{}), []int64{3, 4, 5}; !reflect.DeepEqual(got, want) {
+				t.Errorf("List() IDs after purging = %v, want %v", got, want)
+			}
+			if got := countRaces(t, racingDB); got != tt.stored {
+				t.Errorf("%d races stored after purging, want %d", got, tt.stored)
+			}
+
+			// Purged races aren't purged again.
+			if purged, err := repo.Purge(context.Background(), cutoff, false); err != nil || purged != 0 {
+				t.Errorf("Purge() again = %d, %v, want 0, nil", purged, err)
+			}
+		})
+	}
+}
+
+func TestListLocalStartTimeInTimezone(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start.Add(30*time.Minute)),
+	})
+
+	tests := []struct {
+		timezone string
+		want     map[int64]string
+	}{
+		{timezone: "Australia/Perth", want: map[int64]string{1: "2030-01-15T10:00:00+08:00", 2: "2030-01-15T10:30:00+08:00"}},
+		// Sydney is on daylight saving time in January.
+		{timezone: "Australia/Sydney", want: map[int64]string{1: "2030-01-15T13:00:00+11:00", 2: "2030-01-15T13:30:00+11:00"}},
+		{timezone: "UTC", want: map[int64]string{1: "2030-01-15T02:00:00Z", 2: "2030-01-15T02:30:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{Timezone: tt.timezone})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[int64]string, len(races))
+			for _, race := range races {
+				got[race.Id] = race.LocalStartTime
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() local start times = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{Timezone: "Australia/Nowhere"}); err == nil {
+		t.Error("List() in an unknown timezone succeeded, want an error")
+	}
+}
+
+func TestListFeatureOnlyOnePerMeeting(t *testing.T) {
+	racingDB := dbtest.Open(t, DriverName)
+
+	// The seeded races have one feature race per meeting.
+	repo := NewRacesRepo(racingDB, nil)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meetings := make(map[int64]bool)
+	for _, race := range all {
+		meetings[race.MeetingId] = true
+	}
+
+	features, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{FeatureOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	featured := make(map[int64]int)
+	for _, race := range features {
+		featured[race.MeetingId]++
+	}
+
+	for meetingID := range meetings {
+		if featured[meetingID] != 1 {
+			t.Errorf("meeting %d has %d feature races, want 1", meetingID, featured[meetingID])
+		}
+	}
+	if len(featured) != len(meetings) {
+		t.Errorf("feature races of %d meetings, want %d", len(featured), len(meetings))
+	}
+}
+
+func TestListReferenceTimeOrder(t *testing.T) {
+	reference := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, reference.Add(-2*time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, reference.Add(time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, reference.Add(-10*time.Minute)),
+		dbtest.NewRace(t, 4, 1, 4, reference.Add(3*time.Hour)),
+		dbtest.NewRace(t, 5, 1, 5, reference.Add(20*time.Minute)),
+		dbtest.NewRace(t, 6, 1, 6, reference),
+	})
+
+	// Races without a start time are ordered last, in either direction.
+	if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 6`); err != nil {
+		t.Fatal(err)
+	}
+
+	referenceTime, _ := ptypes.TimestampProto(reference)
+
+	tests := []struct {
+		name      string
+		direction string
+		want      []int64
+	}{
+		{name: "nearest first", want: []int64{3, 5, 2, 1, 4, 6}},
+		{name: "furthest first", direction: "DESC", want: []int64{4, 1, 2, 5, 3, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{ReferenceTime: referenceTime, OrderDirection: tt.direction}
+			if got := listOrderedIDs(t, repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListLocalStartTimeInMeetingTimezone(t *testing.T) {
+	start := time.Date(2030, time.January, 15, 2, 0, 0, 0, time.UTC)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 4, 1, start),
+		dbtest.NewRace(t, 3, 5, 1, start),
+		dbtest.NewRace(t, 4, 7, 1, start),
+	})
+
+	// Without a meeting timezone or one of the repository, the local start time is left empty.
+	if _, err := racingDB.Exec(`UPDATE meetings SET timezone = '' WHERE id = 7`); err != nil {
+		t.Fatal(err)
+	}
+
+	races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type local struct{ timezone, startTime string }
+
+	got := make(map[int64]local, len(races))
+	for _, race := range races {
+		got[race.Id] = local{race.MeetingTimezone, race.LocalStartTime}
+	}
+
+	want := map[int64]local{
+		1: {"Australia/Melbourne", "2030-01-15T13:00:00+11:00"},
+		2: {"Australia/Perth", "2030-01-15T10:00:00+08:00"},
+		3: {"Pacific/Auckland", "2030-01-15T15:00:00+13:00"},
+		4: {"", ""},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() meeting timezones and local start times = %v, want %v", got, want)
+	}
+}
+
+func TestListOrphansOnly(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 2, 1, start),
+		dbtest.NewRace(t, 3, 99, 1, start),
+	})
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   []int64
+	}{
+		{name: "orphans only", filter: &racing.ListRacesRequestFilter{OrphansOnly: true}, want: []int64{3}},
+		{name: "orphans of listed meetings", filter: &racing.ListRacesRequestFilter{OrphansOnly: true, MeetingIds: []int64{1, 2}}, want: []int64{}},
+		{name: "every race", filter: &racing.ListRacesRequestFilter{}, want: []int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListWithDefaultLocation(t *testing.T) {
+	perth, err := time.LoadLocation("Australia/Perth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The races start either side of midnight in Perth, on the same UTC date.
+	now := time.Date(2030, time.January, 15, 16, 0, 0, 0, time.UTC)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 7, 1, now.Add(-30*time.Minute)),
+		dbtest.NewRace(t, 2, 7, 2, now.Add(30*time.Minute)),
+	}, WithLocation(perth))
+
+	// Meeting 7 has no timezone, so local start times are in the default location.
+	if _, err := racingDB.Exec(`UPDATE meetings SET timezone = '' WHERE id = 7`); err != nil {
+		t.Fatal(err)
+	}
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type derived struct {
+		status    racing.RaceStatus
+		startTime string
+	}
+
+	got := make(map[int64]derived, len(races))
+	for _, race := range races {
+		got[race.Id] = derived{race.Status, race.LocalStartTime}
+	}
+
+	// Statuses compare instants, so they're the same whatever the location.
+	want := map[int64]derived{
+		1: {racing.RaceStatus_CLOSED, "2030-01-15T23:30:00+08:00"},
+		2: {racing.RaceStatus_OPEN, "2030-01-16T00:30:00+08:00"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() statuses and local start times = %v, want %v", got, want)
+	}
+}
+
+func TestListInvalidNumber(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+		dbtest.NewRace(t, 4, 1, 4, start),
+	})
+
+	for _, update := range []string{
+		`UPDATE races SET number = NULL WHERE id = 2`,
+		`UPDATE races SET number = 0 WHERE id = 3`,
+		`UPDATE races SET number = -1 WHERE id = 4`,
+	} {
+		if _, err := racingDB.Exec(update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := listIDs(t, repo, &racing.ListRacesRequestFilter{InvalidNumber: true}), []int64{2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() IDs = %v, want %v", got, want)
+	}
+}
+
+func TestHourlyHistogramAcrossTimezones(t *testing.T) {
+	perth, err := time.LoadLocation("Australia/Perth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, time.Date(2030, time.January, 14, 16, 30, 0, 0, time.UTC)),
+		dbtest.NewRace(t, 2, 1, 2, time.Date(2030, time.January, 15, 1, 10, 0, 0, time.UTC)),
+		dbtest.NewRace(t, 3, 1, 3, time.Date(2030, time.January, 15, 1, 50, 0, 0, time.UTC)),
+		dbtest.NewRace(t, 4, 1, 4, time.Date(2030, time.January, 15, 15, 59, 0, 0, time.UTC)),
+		dbtest.NewRace(t, 5, 1, 5, time.Date(2030, time.January, 14, 11, 0, 0, 0, time.UTC)),
+	}, WithLocation(perth))
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		// want maps each hour to the races starting in it, with every other hour having none.
+		want map[int64]int64
+	}{
+		// Race 5 starts the day before in Perth.
+		{name: "default location", want: map[int64]int64{0: 1, 9: 2, 23: 1}},
+		// Race 4 starts the day after in Auckland.
+		{name: "Auckland", location: auckland, want: map[int64]int64{0: 1, 5: 1, 14: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hours, err := repo.HourlyHistogram(context.Background(), &racing.ListRacesRequestFilter{}, "2030-01-15", tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(hours) != 24 {
+				t.Fatalf("HourlyHistogram() = %d hours, want 24", len(hours))
+			}
+
+			for i, hour := range hours {
+				if hour.Hour != int64(i) || hour.Count != tt.want[int64(i)] {
+					t.Errorf("HourlyHistogram() hour %d = %d races at hour %d, want %d", i, hour.Count, hour.Hour, tt.want[int64(i)])
+				}
+			}
+		})
+	}
+}
+
+func TestGetByMeetingAndNumber(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 2, 2, start),
+		dbtest.NewRace(t, 4, 1, 2, start),
+	})
+
+	tests := []struct {
+		name              string
+		meetingID, number int64
+		// want is the ID of the race returned, or 0 when none is.
+		want int64
+	}{
+		{name: "found", meetingID: 2, number: 2, want: 3},
+		// Of the races numbered alike, the first added is returned.
+		{name: "first added", meetingID: 1, number: 2, want: 2},
+		{name: "missing number", meetingID: 1, number: 3},
+		{name: "missing meeting", meetingID: 3, number: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			race, err := repo.GetByMeetingAndNumber(context.Background(), tt.meetingID, tt.number)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := race.GetId(); got != tt.want {
+				t.Errorf("GetByMeetingAndNumber(%d, %d) ID = %d, want %d", tt.meetingID, tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListMeetingOpenRaceCountAsClockAdvances(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start.Add(time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, start.Add(2*time.Hour)),
+		dbtest.NewRace(t, 4, 2, 1, start.Add(30*time.Minute)),
+	})
+
+	tests := []struct {
+		offset time.Duration
+		// want maps each meeting to its open races.
+		want map[int64]int64
+	}{
+		{offset: -time.Minute, want: map[int64]int64{1: 3, 2: 1}},
+		// Races close as they start.
+		{offset: 0, want: map[int64]int64{1: 2, 2: 1}},
+		{offset: 90 * time.Minute, want: map[int64]int64{1: 1, 2: 0}},
+		{offset: 3 * time.Hour, want: map[int64]int64{1: 0, 2: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.offset.String(), func(t *testing.T) {
+			asOf, _ := ptypes.TimestampProto(start.Add(tt.offset))
+
+			// Whether or not the other races of a meeting are listed, its every open race is counted.
+			for _, filter := range []*racing.ListRacesRequestFilter{
+				{AsOf: asOf, IncludeMeetingCounts: true},
+				{AsOf: asOf, IncludeMeetingCounts: true, Ids: []int64{3}},
+			} {
+				races, err := repo.List(context.Background(), filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, race := range races {
+					if want := tt.want[race.MeetingId]; race.MeetingOpenRaceCount != want {
+						t.Errorf("race %d meeting open race count = %d, want %d", race.Id, race.MeetingOpenRaceCount, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListStillOpenIn(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(30*time.Second)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(10*time.Minute)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Minute)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(-time.Minute)),
+	})
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	// Race 3 starts just as the minute is up, so won't still be open.
+	filter := &racing.ListRacesRequestFilter{AsOf: asOf, StillOpenInSeconds: 60}
+	if got, want := listIDs(t, repo, filter), []int64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() IDs still open in 60s = %v, want %v", got, want)
+	}
+}
+
+func TestListBusinessHoursOnly(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sydney is on daylight saving time in January, but not in July, when race 5 would start after
+	// business hours were the January offset applied.
+	races := []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, time.Date(2030, time.January, 15, 8, 59, 0, 0, sydney)),
+		dbtest.NewRace(t, 2, 1, 2, time.Date(2030, time.January, 15, 9, 0, 0, 0, sydney)),
+		dbtest.NewRace(t, 3, 1, 3, time.Date(2030, time.January, 15, 16, 59, 0, 0, sydney)),
+		dbtest.NewRace(t, 4, 1, 4, time.Date(2030, time.January, 15, 17, 0, 0, 0, sydney)),
+		dbtest.NewRace(t, 5, 1, 5, time.Date(2030, time.July, 15, 16, 30, 0, 0, sydney)),
+		dbtest.NewRace(t, 6, 1, 6, time.Date(2030, time.July, 15, 23, 30, 0, 0, sydney)),
+	}
+
+	repo, racingDB := newTestRepo(t, races, WithLocation(sydney))
+
+	tests := []struct {
+		name     string
+		repo     RacesRepo
+		timezone string
+		want     []int64
+	}{
+		{name: "default hours", repo: repo, want: []int64{2, 3, 5}},
+		{name: "spanning midnight", repo: NewRacesRepo(racingDB, nil, WithLocation(sydney), WithBusinessHours("22:00", "09:00")), want: []int64{1, 6}},
+		// 09:00 to 17:00 in Perth is 12:00 to 20:00 in Sydney in January, and 11:00 to 19:00 in July.
+		{name: "in another timezone", repo: repo, timezone: "Australia/Perth", want: []int64{3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{BusinessHoursOnly: true, Timezone: tt.timezone}
+			if got := listIDs(t, tt.repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenClosedTrend(t *testing.T) {
+	day := time.Date(2030, time.January, 15, 0, 0, 0, 0, time.UTC)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, day.Add(-time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, day.Add(6*time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, day.Add(6*time.Hour+time.Second)),
+		dbtest.NewRace(t, 4, 1, 4, day.Add(13*time.Hour)),
+		dbtest.NewRace(t, 5, 1, 5, day.Add(25*time.Hour)),
+		dbtest.NewRace(t, 6, 1, 6, day.Add(10*time.Hour)),
+		dbtest.NewRace(t, 7, 1, 7, day.Add(10*time.Hour)),
+	})
+
+	// Cancelled races and races without a start time are neither open nor closed.
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 7`); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := repo.OpenClosedTrend(context.Background(), &racing.ListRacesRequestFilter{IncludeCancelled: true}, "2030-01-15", 6*time.Hour, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Race 2 has closed by the point it starts at, while race 3 is still open.
+	want := []struct {
+		at           time.Time
+		open, closed int64
+	}{
+		{at: day, open: 4, closed: 1},
+		{at: day.Add(6 * time.Hour), open: 3, closed: 2},
+		{at: day.Add(12 * time.Hour), open: 2, closed: 3},
+		{at: day.Add(18 * time.Hour), open: 1, closed: 4},
+	}
+
+	if len(points) != len(want) {
+		t.Fatalf("OpenClosedTrend() = %d points, want %d", len(points), len(want))
+	}
+
+	for i, point := range points {
+		if !point.At.AsTime().Equal(want[i].at) || point.Open != want[i].open || point.Closed != want[i].closed {
+			t.Errorf("OpenClosedTrend() point %d = %d open and %d closed at %s, want %d and %d at %s", i, point.Open, point.Closed, point.At.AsTime(), want[i].open, want[i].closed, want[i].at)
+		}
+	}
+}
+
+func TestListStatusPreset(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(2*time.Hour)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(time.Hour)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(-time.Hour)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(-2*time.Hour)),
+		dbtest.NewRace(t, 5, 1, 5, now.Add(-3*time.Hour)),
+		dbtest.NewRace(t, 6, 1, 6, now.Add(30*time.Minute)),
+		dbtest.NewRace(t, 7, 1, 7, now),
+	})
+
+	if _, err := repo.SetResult(context.Background(), 5, []*racing.Placing{{Position: 1, RunnerNumber: 1, RunnerName: "Winx"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := racingDB.Exec(`UPDATE races SET advertised_start_time = NULL WHERE id = 7`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   []int64
+	}{
+		{name: "now", want: []int64{2, 1, 4, 3, 5, 6, 7}},
+		// Race 2 has closed by then, so moves to the closed band.
+		{name: "later", offset: 90 * time.Minute, want: []int64{1, 4, 3, 2, 5, 6, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf, _ := ptypes.TimestampProto(now.Add(tt.offset))
+
+			filter := &racing.ListRacesRequestFilter{AsOf: asOf, IncludeCancelled: true, SortPreset: racing.SortPreset_STATUS}
+			if got := listOrderedIDs(t, repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataQuality(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 99, 1, start),
+		dbtest.NewRace(t, 3, 1, 2, start),
+		dbtest.NewRace(t, 4, 1, 3, start),
+		dbtest.NewRace(t, 5, 1, 4, start),
+		hiddenRace(dbtest.NewRace(t, 6, 1, 5, start)),
+		dbtest.NewRace(t, 7, 2, 1, start),
+		hiddenRace(dbtest.NewRace(t, 8, 2, 2, start)),
+		dbtest.NewRace(t, 9, 99, 0, time.Now().Add(-48*time.Hour)),
+	}, WithSoftPurge())
+
+	hideMeetings(t, racingDB, 2)
+
+	// Race 4 both lacks a start time and is numbered 0, while purged race 9 isn't checked.
+	for _, update := range []string{
+		`UPDATE races SET advertised_start_time = NULL WHERE id IN (3, 4)`,
+		`UPDATE races SET number = 0 WHERE id = 4`,
+		`UPDATE races SET number = NULL WHERE id = 5`,
+	} {
+		if _, err := racingDB.Exec(update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.Purge(context.Background(), time.Now().Add(-24*time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := repo.DataQuality(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &racing.DataQualityReport{Races: 8, Orphans: 1, MissingStartTimes: 2, InvalidNumbers: 2, VisibilityMismatches: 2}
+	if !proto.Equal(report, want) {
+		t.Errorf("DataQuality() = %v, want %v", report, want)
+	}
+}
+
+func TestListNthUpcoming(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(-time.Minute)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(3*time.Minute)),
+		hiddenRace(dbtest.NewRace(t, 3, 1, 3, now.Add(time.Minute))),
+		dbtest.NewRace(t, 4, 2, 1, now.Add(2*time.Minute)),
+		dbtest.NewRace(t, 5, 2, 2, now.Add(4*time.Minute)),
+	})
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	// The closed and hidden races aren't upcoming.
+	tests := []struct {
+		n    int64
+		want []int64
+	}{
+		{n: 1, want: []int64{4}},
+		{n: 2, want: []int64{2}},
+		{n: 3, want: []int64{5}},
+		{n: 4, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			if got := listIDs(t, repo, &racing.ListRacesRequestFilter{AsOf: asOf, NthUpcoming: tt.n}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListClosedGrace(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(time.Minute)),
+		dbtest.NewRace(t, 2, 1, 2, now.Add(-30*time.Second)),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(-time.Minute)),
+		dbtest.NewRace(t, 4, 1, 4, now.Add(-2*time.Minute)),
+	})
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	tests := []struct {
+		name  string
+		grace int64
+		want  map[int64]racing.RaceStatus
+	}{
+		{name: "without grace", want: map[int64]racing.RaceStatus{1: racing.RaceStatus_OPEN}},
+		// Races closed within the window are kept with their status, while race 3 closed just as
+		// long ago as the window lasts.
+		{name: "within grace", grace: 60, want: map[int64]racing.RaceStatus{1: racing.RaceStatus_OPEN, 2: racing.RaceStatus_CLOSED}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{
+				AsOf:               asOf,
+				Statuses:           []racing.RaceStatus{racing.RaceStatus_OPEN},
+				ClosedGraceSeconds: tt.grace,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[int64]racing.RaceStatus, len(races))
+			for _, race := range races {
+				got[race.Id] = race.Status
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() statuses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibilitySummaryMatchesSeed(t *testing.T) {
+	racingDB := dbtest.Open(t, DriverName)
+
+	repo := NewRacesRepo(racingDB, nil)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var visible, hidden int64
+	if err := racingDB.QueryRow(`SELECT SUM(visible = 1), SUM(visible = 0) FROM races`).Scan(&visible, &hidden); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *racing.ListRacesRequestFilter
+		want   *racing.VisibilitySummary
+	}{
+		{name: "every race", filter: &racing.ListRacesRequestFilter{}, want: &racing.VisibilitySummary{Visible: visible, Hidden: hidden}},
+		{name: "visible only", filter: &racing.ListRacesRequestFilter{VisibleOnly: true}, want: &racing.VisibilitySummary{Visible: visible}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := repo.VisibilitySummary(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !proto.Equal(summary, tt.want) {
+				t.Errorf("VisibilitySummary() = %v, want %v", summary, tt.want)
+			}
+		})
+	}
+}
+
+func TestListUpdatedBetween(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	start := now.Add(time.Hour)
+	repo, racingDB := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, start),
+		dbtest.NewRace(t, 2, 1, 2, start),
+		dbtest.NewRace(t, 3, 1, 3, start),
+		dbtest.NewRace(t, 4, 1, 4, start),
+		dbtest.NewRace(t, 5, 1, 5, start),
+		dbtest.NewRace(t, 6, 1, 6, start),
+	})
+
+	// Race 1 is never updated, while races 2 to 5 were updated a few hours ago.
+	after, before := now.Add(-3*time.Hour), now.Add(-2*time.Hour)
+	for id, updated := range map[int64]time.Time{
+		2: after.Add(-time.Second),
+		3: after,
+		4: after.Add(30 * time.Minute),
+		5: before,
+	} {
+		if _, err := racingDB.Exec(`UPDATE races SET updated_at = ? WHERE id = ?`, updated.Format(time.RFC3339), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cancelling a race updates it.
+	if _, err := repo.Cancel(context.Background(), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	window := func(after, before time.Time) *racing.TimeRange {
+		afterTS, _ := ptypes.TimestampProto(after)
+		beforeTS, _ := ptypes.TimestampProto(before)
+
+		return &racing.TimeRange{After: afterTS, Before: beforeTS}
+	}
+
+	tests := []struct {
+		name   string
+		window *racing.TimeRange
+		want   []int64
+	}{
+		{name: "inclusive of both ends", window: window(after, before), want: []int64{3, 4, 5}},
+		{name: "just now", window: window(now.Add(-time.Minute), now.Add(time.Minute)), want: []int64{6}},
+		{name: "none updated", window: window(now.Add(-time.Hour), now.Add(-time.Minute)), want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &racing.ListRacesRequestFilter{UpdatedBetween: tt.window, IncludeCancelled: true}
+			if got := listIDs(t, repo, filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListStatusesAreTheSameInEveryTimezone(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo, _ := newTestRepo(t, []*racing.Race{
+		dbtest.NewRace(t, 1, 1, 1, now.Add(-time.Second)),
+		dbtest.NewRace(t, 2, 1, 2, now),
+		dbtest.NewRace(t, 3, 1, 3, now.Add(time.Second)),
+		dbtest.NewRace(t, 4, 5, 1, now.Add(-8*time.Hour)),
+		dbtest.NewRace(t, 5, 4, 1, now.Add(13*time.Hour)),
+	})
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	// listed returns the races listed in each status as at now, with local start times in the
+	// timezone.
+	listed := func(timezone string) map[racing.RaceStatus][]int64 {
+		t.Helper()
+
+		statuses := make(map[racing.RaceStatus][]int64)
+		for _, status := range []racing.RaceStatus{racing.RaceStatus_OPEN, racing.RaceStatus_CLOSED} {
+			races, err := repo.List(context.Background(), &racing.ListRacesRequestFilter{AsOf: asOf, Timezone: timezone, Statuses: []racing.RaceStatus{status}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, race := range races {
+				if race.Status != status {
+					t.Errorf("race %d listed as %v in %q has status %v", race.Id, status, timezone, race.Status)
+				}
+				statuses[status] = append(statuses[status], race.Id)
+			}
+		}
+
+		return statuses
+	}
+
+	want := listed("")
+	if !reflect.DeepEqual(want, map[racing.RaceStatus][]int64{racing.RaceStatus_OPEN: {3, 5}, racing.RaceStatus_CLOSED: {4, 1, 2}}) {
+		t.Fatalf("List() by status = %v, want races 3 and 5 open, and the rest closed", want)
+	}
+
+	for _, timezone := range []string{"Australia/Perth", "Pacific/Auckland", "America/Los_Angeles"} {
+		if got := listed(timezone); !reflect.DeepEqual(got, want) {
+			t.Errorf("List() by status in %s = %v, want %v as without a timezone", timezone, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverName is the name of the SQLite driver repositories must be opened with, which extends
+// SQLite with the REGEXP operator.
+const DriverName = "sqlite3_regexp"
+
+func init() {
+	sql.Register(DriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// SQLite evaluates "value REGEXP pattern" as regexp(pattern, value).
+			return conn.RegisterFunc("regexp", matchRegexp, true)
+		},
+	})
+}
+
+// compiledPatterns caches the patterns matchRegexp has compiled, by pattern, as it's called for
+// every row scanned. Patterns that fail to compile aren't cached.
+var compiledPatterns sync.Map
+
+// matchRegexp reports whether the value matches the Go regular expression pattern. NULL values
+// match no pattern.
+func matchRegexp(pattern string, value interface{}) (bool, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	switch value := value.(type) {
+	case string:
+		return re.MatchString(value), nil
+	case []byte:
+		if value == nil {
+			return false, nil
+		}
+
+		return re.Match(value), nil
+	default:
+		return false, nil
+	}
+}
+
+// compilePattern returns the compiled pattern, compiling it only the first time it's seen.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledPatterns.Store(pattern, re)
+
+	return re, nil
+}
@@ -1,19 +1,71 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	// Timezones are embedded, so local start times don't depend on the host's timezone database.
+	_ "time/tzdata"
 
 	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/metrics"
+	"git.neds.sh/matty/entain/racing/notifier"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"git.neds.sh/matty/entain/racing/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var (
-	grpcEndpoint = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	grpcEndpoint  = flag.String("grpc-endpoint", "localhost:9000", "gRPC server endpoint")
+	admin         = flag.Bool("admin", false, "Enable admin-only reports and RPCs")
+	maxQueries    = flag.Int("max-concurrent-queries", 0, "Maximum concurrent database queries, or 0 for no limit")
+	rejectExcess  = flag.Bool("reject-excess-queries", false, "Reject queries beyond -max-concurrent-queries, rather than queueing them")
+	holidaysFile  = flag.String("holidays-file", "", "File listing public holiday dates, one YYYY-MM-DD date per line")
+	bettingCutoff = flag.Duration("betting-cutoff", 0, "How long before its advertised start time betting on a race closes")
+	defaultTZ     = flag.String("default-timezone", "", "IANA name of the timezone dates are in, such as of holidays, rather than the server's local time")
+	strictTimes   = flag.Bool("strict-timestamps", false, "Fail startup, and reading the race, if a stored race start time isn't RFC3339")
+	raceCacheTTL  = flag.Duration("race-cache-ttl", 0, "How long GetRace serves a race from cache, or 0 to disable caching")
+	softPurge     = flag.Bool("soft-purge", false, "Purge races by hiding them, rather than deleting them")
+	businessHours = flag.String("business-hours", "09:00-17:00", "Clock times business hours start and end at, as HH:MM-HH:MM")
+	maxIDs        = flag.Int("max-ids", 500, "Maximum IDs a request can list, such as in the ids filter, or 0 for no limit")
+	maxSpan       = flag.Duration("max-start-time-span", 366*24*time.Hour, "Longest window a request can filter start times over, between start_time_after and start_time_before, or 0 for no limit")
+
+	defaultVisible = flag.Bool("default-new-race-visible", false, "Make races created without a visibility visible, rather than hidden")
+
+	maxOpenConns    = flag.Int("db-max-open-conns", 0, "Maximum open database connections, or 0 for no limit")
+	maxIdleConns    = flag.Int("db-max-idle-conns", 2, "Maximum idle database connections kept for reuse, or 0 to keep none")
+	connMaxLifetime = flag.Duration("db-conn-max-lifetime", 0, "How long a database connection is reused before it's closed, or 0 to reuse it indefinitely")
+
+	closedWebhookURL      = flag.String("closed-webhook-url", "", "URL to POST visible races to as they close, or empty to disable")
+	closedWebhookInterval = flag.Duration("closed-webhook-interval", 10*time.Second, "How often to check for closed races to POST")
+	closedWebhookRetries  = flag.Int("closed-webhook-retries", 3, "Times to retry a failed closed race POST")
+
+	failureRate = flag.Float64("inject-failure-rate", 0, "Fraction of requests to fail on purpose, between 0 and 1. Requires -admin")
+	failureCode = flag.String("inject-failure-code", "UNAVAILABLE", "gRPC status code of injected failures, such as UNAVAILABLE")
+
+	tlsCertFile     = flag.String("tls-cert-file", "", "PEM certificate to serve gRPC over TLS with, or empty to serve plaintext")
+	tlsKeyFile      = flag.String("tls-key-file", "", "PEM private key of -tls-cert-file")
+	tlsClientCAFile = flag.String("tls-client-ca-file", "", "PEM CA certificates client certificates must be signed by, or empty to not require them")
+
+	metricsEndpoint   = flag.String("metrics-endpoint", "", "Endpoint serving Prometheus metrics at /metrics, or empty to disable")
+	openRacesInterval = flag.Duration("open-races-interval", 15*time.Second, "How often to update the open races gauge")
+
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "How long RPCs in flight, such as WatchRaces streams, have to finish on shutdown, before they're cancelled")
 )
 
 func main() {
@@ -30,30 +82,166 @@ func run() error {
 		return err
 	}
 
-	racingDB, err := sql.Open("sqlite3", "./db/racing.db")
+	// The server drains, and background work stops, once it's asked to terminate.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	racingDB, err := sql.Open(db.DriverName, "./db/racing.db")
+	if err != nil {
+		return err
+	}
+
+	racingDB.SetMaxOpenConns(*maxOpenConns)
+	racingDB.SetMaxIdleConns(*maxIdleConns)
+	racingDB.SetConnMaxLifetime(*connMaxLifetime)
+
+	var holidays []string
+	if *holidaysFile != "" {
+		if holidays, err = db.LoadHolidays(*holidaysFile); err != nil {
+			return err
+		}
+	}
+
+	businessStart, businessEnd, err := db.ParseBusinessHours(*businessHours)
 	if err != nil {
 		return err
 	}
 
-	racesRepo := db.NewRacesRepo(racingDB)
+	limiter := db.NewQueryLimiter(*maxQueries, *rejectExcess)
+
+	repoOpts := []db.RacesRepoOption{
+		db.WithHolidays(holidays),
+		db.WithBettingCutoff(*bettingCutoff),
+		db.WithRaceCache(*raceCacheTTL),
+		db.WithBusinessHours(businessStart, businessEnd),
+	}
+	if *defaultTZ != "" {
+		location, err := time.LoadLocation(*defaultTZ)
+		if err != nil {
+			return fmt.Errorf("invalid -default-timezone %q: %w", *defaultTZ, err)
+		}
+
+		repoOpts = append(repoOpts, db.WithLocation(location))
+	}
+	if *strictTimes {
+		repoOpts = append(repoOpts, db.WithStrictTimestamps())
+	}
+	if *softPurge {
+		repoOpts = append(repoOpts, db.WithSoftPurge())
+	}
+
+	racesRepo := db.NewRacesRepo(racingDB, limiter, repoOpts...)
 	if err := racesRepo.Init(); err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
+	meetingsRepo := db.NewMeetingsRepo(racingDB, limiter)
+
+	if *closedWebhookURL != "" {
+		go notifier.NewClosedRaceNotifier(
+			racesRepo,
+			*closedWebhookURL,
+			*closedWebhookInterval,
+			*closedWebhookRetries,
+			time.Second,
+		).Run(ctx)
+	}
+
+	// RPCs are only recorded when metrics are served, but they're logged regardless.
+	var rpcMetrics *metrics.RPCMetrics
+
+	if *metricsEndpoint != "" {
+		openRaces := metrics.NewOpenRaceGauge(racesRepo, *openRacesInterval)
+		if err := prometheus.Register(openRaces); err != nil {
+			return err
+		}
+
+		rpcMetrics = metrics.NewRPCMetrics()
+		if err := prometheus.Register(rpcMetrics); err != nil {
+			return err
+		}
+
+		go openRaces.Run(ctx)
+		go serveMetrics(*metricsEndpoint)
+	}
+
+	// Every RPC is observed, including those failed or rejected by the interceptors after it.
+	interceptors := []grpc.UnaryServerInterceptor{service.NewObserver(rpcMetrics)}
+
+	// Failures are only injected when asked for, so the interceptor is otherwise absent.
+	if *failureRate > 0 {
+		interceptor, err := failureInjector(*failureRate, *failureCode)
+		if err != nil {
+			return err
+		}
+
+		interceptors = append(interceptors, interceptor)
+	}
+
+	// Malformed requests are rejected after any failure is injected, as a failing server would
+	// fail them regardless.
+	interceptors = append(interceptors, service.NewValidator())
+	interceptors = append(interceptors, service.NewServerClock())
+
+	serverOpts, err := serverCredentials(*tlsCertFile, *tlsKeyFile, *tlsClientCAFile)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer(append(
+		serverOpts,
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(service.NewStreamObserver(rpcMetrics), service.NewStreamValidator()),
+	)...)
 
 	racing.RegisterRacingServer(
 		grpcServer,
 		service.NewRacingService(
 			racesRepo,
+			meetingsRepo,
+			*admin,
+			*maxIDs,
+			*defaultVisible,
+			*maxSpan,
 		),
 	)
 
 	log.Printf("gRPC server listening on: %s\n", *grpcEndpoint)
 
-	if err := grpcServer.Serve(conn); err != nil {
-		return err
+	return serve(ctx, grpcServer, conn, racesRepo, *shutdownTimeout)
+}
+
+// failureInjector returns the interceptor injecting failures with the named code into the
+// given fraction of requests. Injecting failures requires admin mode.
+func failureInjector(rate float64, codeName string) (grpc.UnaryServerInterceptor, error) {
+	if !*admin {
+		return nil, errors.New("-inject-failure-rate requires -admin")
+	}
+
+	if rate > 1 {
+		return nil, fmt.Errorf("invalid -inject-failure-rate %v: must be between 0 and 1", rate)
 	}
 
-	return nil
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(codeName)))); err != nil {
+		return nil, fmt.Errorf("invalid -inject-failure-code %q: %w", codeName, err)
+	}
+
+	if code == codes.OK {
+		return nil, errors.New("-inject-failure-code can't be OK")
+	}
+
+	return service.NewFailureInjector(rate, code), nil
+}
+
+// serveMetrics serves the registered Prometheus metrics at /metrics on the endpoint.
+func serveMetrics(endpoint string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Printf("metrics server listening on: %s\n", endpoint)
+
+	if err := http.ListenAndServe(endpoint, mux); err != nil {
+		log.Printf("failed serving metrics: %s\n", err)
+	}
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// OpenRaceGauge is a Prometheus collector exposing the number of open races, as at its last
+// update.
+type OpenRaceGauge struct {
+	racesRepo db.RacesRepo
+	gauge     prometheus.Gauge
+	interval  time.Duration
+}
+
+// NewOpenRaceGauge creates a gauge of the open races, counted every interval once running.
+func NewOpenRaceGauge(racesRepo db.RacesRepo, interval time.Duration) *OpenRaceGauge {
+	return &OpenRaceGauge{
+		racesRepo: racesRepo,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "racing_open_races",
+			Help: "Number of races open, being neither cancelled nor started.",
+		}),
+		interval: interval,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (g *OpenRaceGauge) Describe(ch chan<- *prometheus.Desc) {
+	g.gauge.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (g *OpenRaceGauge) Collect(ch chan<- prometheus.Metric) {
+	g.gauge.Collect(ch)
+}
+
+// Run updates the gauge now and then every interval, until the context is done.
+func (g *OpenRaceGauge) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	g.update(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.update(ctx, now)
+		}
+	}
+}
+
+// update sets the gauge to the number of races open as at now. The gauge keeps its previous
+// value when counting fails.
+func (g *OpenRaceGauge) update(ctx context.Context, now time.Time) {
+	asOf, err := ptypes.TimestampProto(now)
+	if err != nil {
+		log.Printf("failed counting open races: %s\n", err)
+		return
+	}
+
+	summary, err := g.racesRepo.StatusSummary(ctx, &racing.ListRacesRequestFilter{AsOf: asOf})
+	if err != nil {
+		log.Printf("failed counting open races: %s\n", err)
+		return
+	}
+
+	g.gauge.Set(float64(summary.Open))
+}
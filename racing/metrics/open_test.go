@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+)
+
+func TestOpenRaceGaugeFollowsClock(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	racingDB := dbtest.Open(t, db.DriverName)
+	repo := db.NewRacesRepo(racingDB, nil)
+	dbtest.Load(t, racingDB, repo, dbtest.NewRaces(t, start.Add(time.Minute), start.Add(2*time.Minute), start.Add(3*time.Minute))...)
+	gauge := NewOpenRaceGauge(repo, time.Minute)
+
+	// Each update is as at the tick of a clock advanced past another race's start.
+	tests := []struct {
+		now  time.Time
+		want float64
+	}{
+		{now: start, want: 3},
+		{now: start.Add(90 * time.Second), want: 2},
+		{now: start.Add(150 * time.Second), want: 1},
+		{now: start.Add(4 * time.Minute), want: 0},
+	}
+
+	for _, tt := range tests {
+		gauge.update(context.Background(), tt.now)
+
+		if got := testutil.ToFloat64(gauge); got != tt.want {
+			t.Errorf("open races as at %s = %v, want %v", tt.now.Sub(start), got, tt.want)
+		}
+	}
+}
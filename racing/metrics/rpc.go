@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// RPCMetrics is a Prometheus collector of the RPCs served, counting them by method and status
+// code, and timing them by method.
+type RPCMetrics struct {
+	requests  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+}
+
+// NewRPCMetrics creates an empty collector of the RPCs served.
+func NewRPCMetrics() *RPCMetrics {
+	return &RPCMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "racing_grpc_requests_total",
+			Help: "Number of RPCs served, by method and status code.",
+		}, []string{"method", "code"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "racing_grpc_request_duration_seconds",
+			Help:    "How long RPCs took to serve, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *RPCMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.durations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *RPCMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.durations.Collect(ch)
+}
+
+// Observe records an RPC of the full method name that ended with the code after the elapsed
+// time. Observing a nil collector does nothing, so RPCs can be observed with metrics disabled.
+func (m *RPCMetrics) Observe(method string, code codes.Code, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.requests.WithLabelValues(method, code.String()).Inc()
+	m.durations.WithLabelValues(method).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,139 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// ClosedRaceNotifier watches for races closing, posting each newly closed race to a webhook.
+// Only visible races are posted, and cancelled races never close.
+type ClosedRaceNotifier struct {
+	racesRepo db.RacesRepo
+	client    *http.Client
+	url       string
+	interval  time.Duration
+	retries   int
+	backoff   time.Duration
+}
+
+// NewClosedRaceNotifier creates a notifier that checks for closed races every interval and
+// posts them to the webhook url. Failed posts are retried up to retries times, waiting twice as
+// long before each attempt, starting from backoff.
+func NewClosedRaceNotifier(racesRepo db.RacesRepo, url string, interval time.Duration, retries int, backoff time.Duration) *ClosedRaceNotifier {
+	return &ClosedRaceNotifier{
+		racesRepo: racesRepo,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		url:       url,
+		interval:  interval,
+		retries:   retries,
+		backoff:   backoff,
+	}
+}
+
+// Run notifies the webhook of races closing from now on, until the context is done. Races that
+// closed before Run was called are not notified.
+func (n *ClosedRaceNotifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	since := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := n.notifyClosed(ctx, since, now); err != nil {
+				log.Printf("failed notifying closed races: %s\n", err)
+				continue
+			}
+
+			since = now
+		}
+	}
+}
+
+// notifyClosed posts every visible race that closed after since, up until now.
+func (n *ClosedRaceNotifier) notifyClosed(ctx context.Context, since, now time.Time) error {
+	asOf, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return err
+	}
+
+	closedSince, err := ptypes.TimestampProto(since)
+	if err != nil {
+		return err
+	}
+
+	races, err := n.racesRepo.List(ctx, &racing.ListRacesRequestFilter{
+		ClosedSince: closedSince,
+		AsOf:        asOf,
+		VisibleOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		// A race that can't be delivered is logged and skipped, so it doesn't hold up the rest.
+		if err := n.post(ctx, race); err != nil {
+			log.Printf("failed notifying closed race %d: %s\n", race.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// post delivers the race to the webhook, retrying with an exponential backoff on failure.
+func (n *ClosedRaceNotifier) post(ctx context.Context, race *racing.Race) error {
+	body, err := protojson.Marshal(race)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.backoff
+
+	for attempt := 0; ; attempt++ {
+		if err = n.send(ctx, body); err == nil || attempt == n.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func (n *ClosedRaceNotifier) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
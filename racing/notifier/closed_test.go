@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+func TestNotifyClosedPostsEachRaceOnce(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	var (
+		mu       sync.Mutex
+		posted   = make(map[int64]int)
+		attempts = make(map[int64]int)
+	)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		var race racing.Race
+		if err := protojson.Unmarshal(body, &race); err != nil {
+			t.Errorf("webhook body %q isn't a race: %s", body, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Each race's first delivery fails, so it's retried.
+		attempts[race.Id]++
+		if attempts[race.Id] == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		posted[race.Id]++
+	}))
+	defer webhook.Close()
+
+	racingDB := dbtest.Open(t, db.DriverName)
+	repo := db.NewRacesRepo(racingDB, nil)
+
+	// Races 4 and 5 close within the checks too, but are hidden and cancelled respectively.
+	races := dbtest.NewRaces(t, start.Add(1*time.Second), start.Add(3*time.Second), start.Add(time.Hour), start.Add(time.Second), start.Add(time.Second))
+	races[3].Visible = false
+	dbtest.Load(t, racingDB, repo, races...)
+
+	if _, err := repo.Cancel(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewClosedRaceNotifier(repo, webhook.URL, time.Second, 2, time.Millisecond)
+
+	// Each check covers the interval since the previous, as Run's ticks do.
+	for since, now := start, start.Add(2*time.Second); now.Before(start.Add(10 * time.Second)); since, now = now, now.Add(2*time.Second) {
+		if err := n.notifyClosed(context.Background(), since, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[int64]int{1: 1, 2: 1}
+	if !reflect.DeepEqual(posted, want) {
+		t.Errorf("races posted = %v, want %v", posted, want)
+	}
+}
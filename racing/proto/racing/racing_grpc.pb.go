@@ -20,6 +20,59 @@ const _ = grpc.SupportPackageIsVersion7
 type RacingClient interface {
 	// ListRaces will return a collection of all races.
 	ListRaces(ctx context.Context, in *ListRacesRequest, opts ...grpc.CallOption) (*ListRacesResponse, error)
+	// GetRace returns a single race by its ID.
+	GetRace(ctx context.Context, in *GetRaceRequest, opts ...grpc.CallOption) (*Race, error)
+	// GetRaceByMeetingAndNumber returns a single race by its meeting and number.
+	GetRaceByMeetingAndNumber(ctx context.Context, in *GetRaceByMeetingAndNumberRequest, opts ...grpc.CallOption) (*Race, error)
+	// CancelRace marks a race as cancelled, returning the updated race. Requires
+	// admin mode.
+	CancelRace(ctx context.Context, in *CancelRaceRequest, opts ...grpc.CallOption) (*Race, error)
+	// CreateRace adds a race, returning it as stored with its ID assigned and
+	// status derived. Requires admin mode.
+	CreateRace(ctx context.Context, in *CreateRaceRequest, opts ...grpc.CallOption) (*Race, error)
+	// UpdateRace updates the fields of a race named by the update mask, leaving
+	// the rest untouched, and returns the updated race. Requires admin mode.
+	UpdateRace(ctx context.Context, in *UpdateRaceRequest, opts ...grpc.CallOption) (*Race, error)
+	// SetRaceResult records the placings of a race that has run, replacing any
+	// result already recorded, and returns the result. Races with a result are
+	// SETTLED. Requires admin mode.
+	SetRaceResult(ctx context.Context, in *SetRaceResultRequest, opts ...grpc.CallOption) (*RaceResult, error)
+	// GetRaceResult returns the result recorded for a race.
+	GetRaceResult(ctx context.Context, in *GetRaceResultRequest, opts ...grpc.CallOption) (*RaceResult, error)
+	// ListMeetings returns all meetings, each with a summary of its races.
+	ListMeetings(ctx context.Context, in *ListMeetingsRequest, opts ...grpc.CallOption) (*ListMeetingsResponse, error)
+	// GetStatusSummary returns the number of races in each status, among the
+	// races matching the filter.
+	GetStatusSummary(ctx context.Context, in *GetStatusSummaryRequest, opts ...grpc.CallOption) (*StatusSummary, error)
+	// GetVisibilitySummary returns the number of visible and hidden races, among
+	// the races matching the filter.
+	GetVisibilitySummary(ctx context.Context, in *GetVisibilitySummaryRequest, opts ...grpc.CallOption) (*VisibilitySummary, error)
+	// ListRaceNumbers returns the distinct numbers of the races that aren't
+	// cancelled.
+	ListRaceNumbers(ctx context.Context, in *ListRaceNumbersRequest, opts ...grpc.CallOption) (*ListRaceNumbersResponse, error)
+	// ListStartTimeClashes returns the start times shared by races of different
+	// meetings, with the clashing races. Requires admin mode.
+	ListStartTimeClashes(ctx context.Context, in *ListStartTimeClashesRequest, opts ...grpc.CallOption) (*ListStartTimeClashesResponse, error)
+	// GetDataQualityReport counts the races with each kind of data defect.
+	// Requires admin mode.
+	GetDataQualityReport(ctx context.Context, in *GetDataQualityReportRequest, opts ...grpc.CallOption) (*DataQualityReport, error)
+	// RaceTimeline groups the races matching the filter into buckets of a fixed
+	// width by their start time, counting the races in each.
+	RaceTimeline(ctx context.Context, in *RaceTimelineRequest, opts ...grpc.CallOption) (*RaceTimelineResponse, error)
+	// PurgeRaces removes the races that started more than the given age ago,
+	// returning how many were removed. Requires admin mode.
+	PurgeRaces(ctx context.Context, in *PurgeRacesRequest, opts ...grpc.CallOption) (*PurgeRacesResponse, error)
+	// RaceHourlyHistogram counts the races matching the filter starting in each
+	// hour of a day.
+	RaceHourlyHistogram(ctx context.Context, in *RaceHourlyHistogramRequest, opts ...grpc.CallOption) (*RaceHourlyHistogramResponse, error)
+	// RaceOpenClosedTrend counts the races matching the filter that were open
+	// and closed at the start of each interval of a day.
+	RaceOpenClosedTrend(ctx context.Context, in *RaceOpenClosedTrendRequest, opts ...grpc.CallOption) (*RaceOpenClosedTrendResponse, error)
+	// WatchRaces streams each race matching the filter when first matched, and
+	// again whenever its status changes, until the client disconnects. Races
+	// that stop matching the filter, such as open races filtered by the OPEN
+	// status once they close, are streamed one last time as removed.
+	WatchRaces(ctx context.Context, in *WatchRacesRequest, opts ...grpc.CallOption) (Racing_WatchRacesClient, error)
 }
 
 type racingClient struct {
@@ -39,12 +92,250 @@ func (c *racingClient) ListRaces(ctx context.Context, in *ListRacesRequest, opts
 	return out, nil
 }
 
+func (c *racingClient) GetRace(ctx context.Context, in *GetRaceRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetRaceByMeetingAndNumber(ctx context.Context, in *GetRaceByMeetingAndNumberRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetRaceByMeetingAndNumber", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) CancelRace(ctx context.Context, in *CancelRaceRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/CancelRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) CreateRace(ctx context.Context, in *CreateRaceRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/CreateRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) UpdateRace(ctx context.Context, in *UpdateRaceRequest, opts ...grpc.CallOption) (*Race, error) {
+	out := new(Race)
+	err := c.cc.Invoke(ctx, "/racing.Racing/UpdateRace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) SetRaceResult(ctx context.Context, in *SetRaceResultRequest, opts ...grpc.CallOption) (*RaceResult, error) {
+	out := new(RaceResult)
+	err := c.cc.Invoke(ctx, "/racing.Racing/SetRaceResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetRaceResult(ctx context.Context, in *GetRaceResultRequest, opts ...grpc.CallOption) (*RaceResult, error) {
+	out := new(RaceResult)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetRaceResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListMeetings(ctx context.Context, in *ListMeetingsRequest, opts ...grpc.CallOption) (*ListMeetingsResponse, error) {
+	out := new(ListMeetingsResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListMeetings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetStatusSummary(ctx context.Context, in *GetStatusSummaryRequest, opts ...grpc.CallOption) (*StatusSummary, error) {
+	out := new(StatusSummary)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetStatusSummary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetVisibilitySummary(ctx context.Context, in *GetVisibilitySummaryRequest, opts ...grpc.CallOption) (*VisibilitySummary, error) {
+	out := new(VisibilitySummary)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetVisibilitySummary", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListRaceNumbers(ctx context.Context, in *ListRaceNumbersRequest, opts ...grpc.CallOption) (*ListRaceNumbersResponse, error) {
+	out := new(ListRaceNumbersResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListRaceNumbers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) ListStartTimeClashes(ctx context.Context, in *ListStartTimeClashesRequest, opts ...grpc.CallOption) (*ListStartTimeClashesResponse, error) {
+	out := new(ListStartTimeClashesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/ListStartTimeClashes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) GetDataQualityReport(ctx context.Context, in *GetDataQualityReportRequest, opts ...grpc.CallOption) (*DataQualityReport, error) {
+	out := new(DataQualityReport)
+	err := c.cc.Invoke(ctx, "/racing.Racing/GetDataQualityReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) RaceTimeline(ctx context.Context, in *RaceTimelineRequest, opts ...grpc.CallOption) (*RaceTimelineResponse, error) {
+	out := new(RaceTimelineResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/RaceTimeline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) PurgeRaces(ctx context.Context, in *PurgeRacesRequest, opts ...grpc.CallOption) (*PurgeRacesResponse, error) {
+	out := new(PurgeRacesResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/PurgeRaces", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) RaceHourlyHistogram(ctx context.Context, in *RaceHourlyHistogramRequest, opts ...grpc.CallOption) (*RaceHourlyHistogramResponse, error) {
+	out := new(RaceHourlyHistogramResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/RaceHourlyHistogram", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) RaceOpenClosedTrend(ctx context.Context, in *RaceOpenClosedTrendRequest, opts ...grpc.CallOption) (*RaceOpenClosedTrendResponse, error) {
+	out := new(RaceOpenClosedTrendResponse)
+	err := c.cc.Invoke(ctx, "/racing.Racing/RaceOpenClosedTrend", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *racingClient) WatchRaces(ctx context.Context, in *WatchRacesRequest, opts ...grpc.CallOption) (Racing_WatchRacesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Racing_ServiceDesc.Streams[0], "/racing.Racing/WatchRaces", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &racingWatchRacesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Racing_WatchRacesClient interface {
+	Recv() (*WatchRacesResponse, error)
+	grpc.ClientStream
+}
+
+type racingWatchRacesClient struct {
+	grpc.ClientStream
+}
+
+func (x *racingWatchRacesClient) Recv() (*WatchRacesResponse, error) {
+	m := new(WatchRacesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // RacingServer is the server API for Racing service.
 // All implementations should embed UnimplementedRacingServer
 // for forward compatibility
 type RacingServer interface {
 	// ListRaces will return a collection of all races.
 	ListRaces(context.Context, *ListRacesRequest) (*ListRacesResponse, error)
+	// GetRace returns a single race by its ID.
+	GetRace(context.Context, *GetRaceRequest) (*Race, error)
+	// GetRaceByMeetingAndNumber returns a single race by its meeting and number.
+	GetRaceByMeetingAndNumber(context.Context, *GetRaceByMeetingAndNumberRequest) (*Race, error)
+	// CancelRace marks a race as cancelled, returning the updated race. Requires
+	// admin mode.
+	CancelRace(context.Context, *CancelRaceRequest) (*Race, error)
+	// CreateRace adds a race, returning it as stored with its ID assigned and
+	// status derived. Requires admin mode.
+	CreateRace(context.Context, *CreateRaceRequest) (*Race, error)
+	// UpdateRace updates the fields of a race named by the update mask, leaving
+	// the rest untouched, and returns the updated race. Requires admin mode.
+	UpdateRace(context.Context, *UpdateRaceRequest) (*Race, error)
+	// SetRaceResult records the placings of a race that has run, replacing any
+	// result already recorded, and returns the result. Races with a result are
+	// SETTLED. Requires admin mode.
+	SetRaceResult(context.Context, *SetRaceResultRequest) (*RaceResult, error)
+	// GetRaceResult returns the result recorded for a race.
+	GetRaceResult(context.Context, *GetRaceResultRequest) (*RaceResult, error)
+	// ListMeetings returns all meetings, each with a summary of its races.
+	ListMeetings(context.Context, *ListMeetingsRequest) (*ListMeetingsResponse, error)
+	// GetStatusSummary returns the number of races in each status, among the
+	// races matching the filter.
+	GetStatusSummary(context.Context, *GetStatusSummaryRequest) (*StatusSummary, error)
+	// GetVisibilitySummary returns the number of visible and hidden races, among
+	// the races matching the filter.
+	GetVisibilitySummary(context.Context, *GetVisibilitySummaryRequest) (*VisibilitySummary, error)
+	// ListRaceNumbers returns the distinct numbers of the races that aren't
+	// cancelled.
+	ListRaceNumbers(context.Context, *ListRaceNumbersRequest) (*ListRaceNumbersResponse, error)
+	// ListStartTimeClashes returns the start times shared by races of different
+	// meetings, with the clashing races. Requires admin mode.
+	ListStartTimeClashes(context.Context, *ListStartTimeClashesRequest) (*ListStartTimeClashesResponse, error)
+	// GetDataQualityReport counts the races with each kind of data defect.
+	// Requires admin mode.
+	GetDataQualityReport(context.Context, *GetDataQualityReportRequest) (*DataQualityReport, error)
+	// RaceTimeline groups the races matching the filter into buckets of a fixed
+	// width by their start time, counting the races in each.
+	RaceTimeline(context.Context, *RaceTimelineRequest) (*RaceTimelineResponse, error)
+	// PurgeRaces removes the races that started more than the given age ago,
+	// returning how many were removed. Requires admin mode.
+	PurgeRaces(context.Context, *PurgeRacesRequest) (*PurgeRacesResponse, error)
+	// RaceHourlyHistogram counts the races matching the filter starting in each
+	// hour of a day.
+	RaceHourlyHistogram(context.Context, *RaceHourlyHistogramRequest) (*RaceHourlyHistogramResponse, error)
+	// RaceOpenClosedTrend counts the races matching the filter that were open
+	// and closed at the start of each interval of a day.
+	RaceOpenClosedTrend(context.Context, *RaceOpenClosedTrendRequest) (*RaceOpenClosedTrendResponse, error)
+	// WatchRaces streams each race matching the filter when first matched, and
+	// again whenever its status changes, until the client disconnects. Races
+	// that stop matching the filter, such as open races filtered by the OPEN
+	// status once they close, are streamed one last time as removed.
+	WatchRaces(*WatchRacesRequest, Racing_WatchRacesServer) error
 }
 
 // UnimplementedRacingServer should be embedded to have forward compatible implementations.
@@ -54,6 +345,60 @@ type UnimplementedRacingServer struct {
 func (UnimplementedRacingServer) ListRaces(context.Context, *ListRacesRequest) (*ListRacesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListRaces not implemented")
 }
+func (UnimplementedRacingServer) GetRace(context.Context, *GetRaceRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRace not implemented")
+}
+func (UnimplementedRacingServer) GetRaceByMeetingAndNumber(context.Context, *GetRaceByMeetingAndNumberRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRaceByMeetingAndNumber not implemented")
+}
+func (UnimplementedRacingServer) CancelRace(context.Context, *CancelRaceRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRace not implemented")
+}
+func (UnimplementedRacingServer) CreateRace(context.Context, *CreateRaceRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRace not implemented")
+}
+func (UnimplementedRacingServer) UpdateRace(context.Context, *UpdateRaceRequest) (*Race, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRace not implemented")
+}
+func (UnimplementedRacingServer) SetRaceResult(context.Context, *SetRaceResultRequest) (*RaceResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRaceResult not implemented")
+}
+func (UnimplementedRacingServer) GetRaceResult(context.Context, *GetRaceResultRequest) (*RaceResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRaceResult not implemented")
+}
+func (UnimplementedRacingServer) ListMeetings(context.Context, *ListMeetingsRequest) (*ListMeetingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMeetings not implemented")
+}
+func (UnimplementedRacingServer) GetStatusSummary(context.Context, *GetStatusSummaryRequest) (*StatusSummary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatusSummary not implemented")
+}
+func (UnimplementedRacingServer) GetVisibilitySummary(context.Context, *GetVisibilitySummaryRequest) (*VisibilitySummary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVisibilitySummary not implemented")
+}
+func (UnimplementedRacingServer) ListRaceNumbers(context.Context, *ListRaceNumbersRequest) (*ListRaceNumbersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRaceNumbers not implemented")
+}
+func (UnimplementedRacingServer) ListStartTimeClashes(context.Context, *ListStartTimeClashesRequest) (*ListStartTimeClashesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStartTimeClashes not implemented")
+}
+func (UnimplementedRacingServer) GetDataQualityReport(context.Context, *GetDataQualityReportRequest) (*DataQualityReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDataQualityReport not implemented")
+}
+func (UnimplementedRacingServer) RaceTimeline(context.Context, *RaceTimelineRequest) (*RaceTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaceTimeline not implemented")
+}
+func (UnimplementedRacingServer) PurgeRaces(context.Context, *PurgeRacesRequest) (*PurgeRacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeRaces not implemented")
+}
+func (UnimplementedRacingServer) RaceHourlyHistogram(context.Context, *RaceHourlyHistogramRequest) (*RaceHourlyHistogramResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaceHourlyHistogram not implemented")
+}
+func (UnimplementedRacingServer) RaceOpenClosedTrend(context.Context, *RaceOpenClosedTrendRequest) (*RaceOpenClosedTrendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RaceOpenClosedTrend not implemented")
+}
+func (UnimplementedRacingServer) WatchRaces(*WatchRacesRequest, Racing_WatchRacesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRaces not implemented")
+}
 
 // UnsafeRacingServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to RacingServer will
@@ -84,6 +429,333 @@ func _Racing_ListRaces_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Racing_GetRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetRace(ctx, req.(*GetRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetRaceByMeetingAndNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRaceByMeetingAndNumberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetRaceByMeetingAndNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetRaceByMeetingAndNumber",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetRaceByMeetingAndNumber(ctx, req.(*GetRaceByMeetingAndNumberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_CancelRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).CancelRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/CancelRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).CancelRace(ctx, req.(*CancelRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_CreateRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).CreateRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/CreateRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).CreateRace(ctx, req.(*CreateRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_UpdateRace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).UpdateRace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/UpdateRace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).UpdateRace(ctx, req.(*UpdateRaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_SetRaceResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRaceResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).SetRaceResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/SetRaceResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).SetRaceResult(ctx, req.(*SetRaceResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetRaceResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRaceResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetRaceResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetRaceResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetRaceResult(ctx, req.(*GetRaceResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListMeetings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMeetingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListMeetings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListMeetings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListMeetings(ctx, req.(*ListMeetingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetStatusSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetStatusSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetStatusSummary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetStatusSummary(ctx, req.(*GetStatusSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetVisibilitySummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVisibilitySummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetVisibilitySummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetVisibilitySummary",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetVisibilitySummary(ctx, req.(*GetVisibilitySummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListRaceNumbers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRaceNumbersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListRaceNumbers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListRaceNumbers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListRaceNumbers(ctx, req.(*ListRaceNumbersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_ListStartTimeClashes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStartTimeClashesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).ListStartTimeClashes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/ListStartTimeClashes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).ListStartTimeClashes(ctx, req.(*ListStartTimeClashesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_GetDataQualityReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataQualityReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).GetDataQualityReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/GetDataQualityReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).GetDataQualityReport(ctx, req.(*GetDataQualityReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_RaceTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaceTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).RaceTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/RaceTimeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).RaceTimeline(ctx, req.(*RaceTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_PurgeRaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).PurgeRaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/PurgeRaces",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).PurgeRaces(ctx, req.(*PurgeRacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_RaceHourlyHistogram_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaceHourlyHistogramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).RaceHourlyHistogram(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/RaceHourlyHistogram",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).RaceHourlyHistogram(ctx, req.(*RaceHourlyHistogramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_RaceOpenClosedTrend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RaceOpenClosedTrendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RacingServer).RaceOpenClosedTrend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/racing.Racing/RaceOpenClosedTrend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RacingServer).RaceOpenClosedTrend(ctx, req.(*RaceOpenClosedTrendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Racing_WatchRaces_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRacesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RacingServer).WatchRaces(m, &racingWatchRacesServer{stream})
+}
+
+type Racing_WatchRacesServer interface {
+	Send(*WatchRacesResponse) error
+	grpc.ServerStream
+}
+
+type racingWatchRacesServer struct {
+	grpc.ServerStream
+}
+
+func (x *racingWatchRacesServer) Send(m *WatchRacesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Racing_ServiceDesc is the grpc.ServiceDesc for Racing service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -95,7 +767,81 @@ var Racing_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListRaces",
 			Handler:    _Racing_ListRaces_Handler,
 		},
+		{
+			MethodName: "GetRace",
+			Handler:    _Racing_GetRace_Handler,
+		},
+		{
+			MethodName: "GetRaceByMeetingAndNumber",
+			Handler:    _Racing_GetRaceByMeetingAndNumber_Handler,
+		},
+		{
+			MethodName: "CancelRace",
+			Handler:    _Racing_CancelRace_Handler,
+		},
+		{
+			MethodName: "CreateRace",
+			Handler:    _Racing_CreateRace_Handler,
+		},
+		{
+			MethodName: "UpdateRace",
+			Handler:    _Racing_UpdateRace_Handler,
+		},
+		{
+			MethodName: "SetRaceResult",
+			Handler:    _Racing_SetRaceResult_Handler,
+		},
+		{
+			MethodName: "GetRaceResult",
+			Handler:    _Racing_GetRaceResult_Handler,
+		},
+		{
+			MethodName: "ListMeetings",
+			Handler:    _Racing_ListMeetings_Handler,
+		},
+		{
+			MethodName: "GetStatusSummary",
+			Handler:    _Racing_GetStatusSummary_Handler,
+		},
+		{
+			MethodName: "GetVisibilitySummary",
+			Handler:    _Racing_GetVisibilitySummary_Handler,
+		},
+		{
+			MethodName: "ListRaceNumbers",
+			Handler:    _Racing_ListRaceNumbers_Handler,
+		},
+		{
+			MethodName: "ListStartTimeClashes",
+			Handler:    _Racing_ListStartTimeClashes_Handler,
+		},
+		{
+			MethodName: "GetDataQualityReport",
+			Handler:    _Racing_GetDataQualityReport_Handler,
+		},
+		{
+			MethodName: "RaceTimeline",
+			Handler:    _Racing_RaceTimeline_Handler,
+		},
+		{
+			MethodName: "PurgeRaces",
+			Handler:    _Racing_PurgeRaces_Handler,
+		},
+		{
+			MethodName: "RaceHourlyHistogram",
+			Handler:    _Racing_RaceHourlyHistogram_Handler,
+		},
+		{
+			MethodName: "RaceOpenClosedTrend",
+			Handler:    _Racing_RaceOpenClosedTrend_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRaces",
+			Handler:       _Racing_WatchRaces_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "racing/racing.proto",
 }
@@ -0,0 +1,344 @@
+package racing
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/order"
+)
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *ListRacesRequest) Validate() error {
+	if x.GetIdsOnly() && x.GetCountOnly() {
+		return status.Error(codes.InvalidArgument, "ids_only and count_only are mutually exclusive")
+	}
+
+	if x.GetPageToken() != "" {
+		if _, err := DecodePageToken(x.PageToken); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid page_token: %s", err)
+		}
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *GetStatusSummaryRequest) Validate() error {
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *GetVisibilitySummaryRequest) Validate() error {
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *RaceTimelineRequest) Validate() error {
+	if x.GetBucketMinutes() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid bucket_minutes: %d", x.BucketMinutes)
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *WatchRacesRequest) Validate() error {
+	if x.GetFilter().GetAsOf() != nil {
+		return status.Error(codes.InvalidArgument, "races are watched as at now, so the filter can't have an as_of")
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *CreateRaceRequest) Validate() error {
+	if x.GetRace() == nil {
+		return status.Error(codes.InvalidArgument, "race is required")
+	}
+
+	if x.Race.Id != 0 {
+		return status.Error(codes.InvalidArgument, "race.id is assigned when the race is created, so it must be unset")
+	}
+
+	if x.Race.MeetingId <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid race.meeting_id: %d", x.Race.MeetingId)
+	}
+
+	if strings.TrimSpace(x.Race.Name) == "" {
+		return status.Error(codes.InvalidArgument, "race.name is required")
+	}
+
+	if x.Race.Number <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid race.number: %d", x.Race.Number)
+	}
+
+	if _, err := ptypes.Timestamp(x.Race.AdvertisedStartTime); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid race.advertised_start_time: %s", err)
+	}
+
+	return nil
+}
+
+// updatableRaceFields are the fields of a race an update mask may name.
+var updatableRaceFields = map[string]bool{
+	"name":                  true,
+	"number":                true,
+	"visible":               true,
+	"advertised_start_time": true,
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *UpdateRaceRequest) Validate() error {
+	if x.GetRace().GetId() <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid race.id: %d", x.GetRace().GetId())
+	}
+
+	if len(x.GetUpdateMask().GetPaths()) == 0 {
+		return status.Error(codes.InvalidArgument, "update_mask must name at least one field")
+	}
+
+	for _, path := range x.UpdateMask.Paths {
+		if !updatableRaceFields[path] {
+			return status.Errorf(codes.InvalidArgument, "invalid update_mask: field %q can't be updated", path)
+		}
+
+		// Races are numbered from 1, so an update can't leave a race without a number.
+		if path == "number" && x.Race.Number <= 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid race.number: %d", x.Race.Number)
+		}
+
+		if path == "name" && strings.TrimSpace(x.Race.Name) == "" {
+			return status.Error(codes.InvalidArgument, "race.name can't be updated to be empty")
+		}
+	}
+
+	if x.Race.AdvertisedStartTime != nil {
+		if _, err := ptypes.Timestamp(x.Race.AdvertisedStartTime); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid race.advertised_start_time: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *SetRaceResultRequest) Validate() error {
+	if x.GetRaceId() <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid race_id: %d", x.GetRaceId())
+	}
+
+	if len(x.GetPlacings()) == 0 {
+		return status.Error(codes.InvalidArgument, "placings must include at least one runner")
+	}
+
+	positions := make(map[int64]bool, len(x.Placings))
+
+	for _, placing := range x.Placings {
+		if placing.GetPosition() <= 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid placings.position: %d", placing.GetPosition())
+		}
+
+		if positions[placing.Position] {
+			return status.Errorf(codes.InvalidArgument, "invalid placings: position %d is placed more than once", placing.Position)
+		}
+
+		positions[placing.Position] = true
+
+		if placing.RunnerNumber <= 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid placings.runner_number: %d", placing.RunnerNumber)
+		}
+
+		if strings.TrimSpace(placing.RunnerName) == "" {
+			return status.Error(codes.InvalidArgument, "placings.runner_name is required")
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *PurgeRacesRequest) Validate() error {
+	if x.GetOlderThanDays() <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid older_than_days: %d", x.GetOlderThanDays())
+	}
+
+	return nil
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *RaceHourlyHistogramRequest) Validate() error {
+	if _, err := time.Parse("2006-01-02", x.GetDate()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid date %q: must be YYYY-MM-DD", x.GetDate())
+	}
+
+	if x.GetTimezone() != "" {
+		if _, err := time.LoadLocation(x.Timezone); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid timezone: %s", err)
+		}
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *RaceOpenClosedTrendRequest) Validate() error {
+	if x.GetBucketMinutes() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid bucket_minutes: %d", x.BucketMinutes)
+	}
+
+	if _, err := time.Parse("2006-01-02", x.GetDate()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid date %q: must be YYYY-MM-DD", x.GetDate())
+	}
+
+	if x.GetTimezone() != "" {
+		if _, err := time.LoadLocation(x.Timezone); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid timezone: %s", err)
+		}
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the filter is malformed. An absent filter is
+// valid, listing races by default.
+func (x *ListRacesRequestFilter) Validate() error {
+	if x.GetAsOf() != nil {
+		if _, err := ptypes.Timestamp(x.AsOf); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid as_of: %s", err)
+		}
+	}
+
+	if x.GetClosedSince() != nil {
+		if _, err := ptypes.Timestamp(x.ClosedSince); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid closed_since: %s", err)
+		}
+	}
+
+	if x.GetStartTimeAfter() != nil {
+		if _, err := ptypes.Timestamp(x.StartTimeAfter); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid start_time_after: %s", err)
+		}
+	}
+
+	if x.GetStartTimeBefore() != nil {
+		if _, err := ptypes.Timestamp(x.StartTimeBefore); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid start_time_before: %s", err)
+		}
+	}
+
+	if x.GetReferenceTime() != nil {
+		if _, err := ptypes.Timestamp(x.ReferenceTime); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid reference_time: %s", err)
+		}
+	}
+
+	if x.GetTimezone() != "" {
+		if _, err := time.LoadLocation(x.Timezone); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid timezone: %s", err)
+		}
+	}
+
+	if x.GetUpdatedBetween() != nil {
+		if err := x.UpdatedBetween.Validate(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid updated_between: %s", err)
+		}
+	}
+
+	if x.GetNameRegexp() != "" {
+		if _, err := regexp.Compile(x.NameRegexp); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid name_regexp: %s", err)
+		}
+	}
+
+	if x.GetOffset() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid offset: %d", x.Offset)
+	}
+
+	if x.GetNthUpcoming() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid nth_upcoming: %d", x.NthUpcoming)
+	}
+
+	if x.GetClosedGraceSeconds() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid closed_grace_seconds: %d", x.ClosedGraceSeconds)
+	}
+
+	if x.GetNextOnly() && x.GetNthUpcoming() > 0 {
+		return status.Error(codes.InvalidArgument, "next_only and nth_upcoming are mutually exclusive")
+	}
+
+	if x.GetFirstRacePerMeeting() && x.GetLastRacePerMeeting() {
+		return status.Error(codes.InvalidArgument, "first_race_per_meeting and last_race_per_meeting are mutually exclusive")
+	}
+
+	if preset := x.GetSortPreset(); preset != SortPreset_SORT_PRESET_UNSPECIFIED {
+		if _, ok := SortPreset_name[int32(preset)]; !ok {
+			return status.Errorf(codes.InvalidArgument, "invalid sort_preset: %d", preset)
+		}
+
+		if x.OrderDirection != "" || x.OrderBy != "" || x.IdAfter > 0 {
+			return status.Error(codes.InvalidArgument, "sort_preset can't be combined with order_direction, order_by or id_after")
+		}
+	}
+
+	if len(x.GetSortBy()) > 0 {
+		if x.OrderDirection != "" || x.OrderBy != "" || x.SortPreset != SortPreset_SORT_PRESET_UNSPECIFIED || x.IdAfter > 0 || x.ReferenceTime != nil {
+			return status.Error(codes.InvalidArgument, "sort_by can't be combined with order_by, order_direction, sort_preset, id_after or reference_time")
+		}
+
+		terms := make([]order.Term, len(x.SortBy))
+		for i, by := range x.SortBy {
+			terms[i] = order.Term{Field: by.GetField().String(), Direction: by.GetDirection().DirectionName()}
+		}
+
+		// Each field may be sorted by once.
+		if err := order.ValidateOrderTerms(terms, sortFields, len(sortFields)); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid sort_by: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an error if the range is missing either end, or ends before it starts.
+func (x *TimeRange) Validate() error {
+	after, err := ptypes.Timestamp(x.GetAfter())
+	if err != nil {
+		return fmt.Errorf("after: %s", err)
+	}
+
+	before, err := ptypes.Timestamp(x.GetBefore())
+	if err != nil {
+		return fmt.Errorf("before: %s", err)
+	}
+
+	if before.Before(after) {
+		return errors.New("before is earlier than after")
+	}
+
+	return nil
+}
+
+// sortFields are the names of the fields races may be sorted by.
+var sortFields = []string{
+	OrderField_name[int32(OrderField_START_TIME)],
+	OrderField_name[int32(OrderField_NAME)],
+	OrderField_name[int32(OrderField_NUMBER)],
+	OrderField_name[int32(OrderField_MEETING)],
+}
+
+// DirectionName returns the name of the direction as parsed by order.ParseDirection, being empty
+// when unspecified so that it defaults to ascending.
+func (x OrderDirection) DirectionName() string {
+	if x == OrderDirection_ORDER_DIRECTION_UNSPECIFIED {
+		return ""
+	}
+
+	return x.String()
+}
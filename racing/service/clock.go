@@ -0,0 +1,31 @@
+package service
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ServerTimeHeader is the response header metadata carrying the time list RPCs were handled at.
+const ServerTimeHeader = "server-time"
+
+// NewServerClock returns an interceptor sending the time each list RPC is handled at, by the
+// clock race statuses are derived by, as RFC3339 in the server-time response header. Clients
+// counting down to start times can then allow for their own clock being off.
+func NewServerClock() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(path.Base(info.FullMethod), "List") {
+			now := time.Now().UTC().Format(time.RFC3339)
+
+			if err := grpc.SetHeader(ctx, metadata.Pairs(ServerTimeHeader, now)); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"math/rand"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewFailureInjector returns an interceptor failing the given fraction of requests with code,
+// so clients can exercise their error handling. Other requests are handled as usual.
+func NewFailureInjector(rate float64, code codes.Code) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if rand.Float64() < rate {
+			return nil, status.Errorf(code, "injected failure of %s", info.FullMethod)
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFailureInjectorRate(t *testing.T) {
+	const requests = 10000
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/ListRaces"}
+
+	tests := []struct {
+		name string
+		rate float64
+	}{
+		// A rate of 0 injects no failures, handling every request as usual.
+		{name: "disabled", rate: 0},
+		{name: "some", rate: 0.3},
+		{name: "every", rate: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewFailureInjector(tt.rate, codes.Unavailable)
+
+			var failed, handled int
+			for i := 0; i < requests; i++ {
+				_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+					handled++
+					return nil, nil
+				})
+
+				switch code := status.Code(err); code {
+				case codes.OK:
+				case codes.Unavailable:
+					failed++
+				default:
+					t.Fatalf("interceptor code = %v, want %v or OK", code, codes.Unavailable)
+				}
+			}
+
+			if failed+handled != requests {
+				t.Errorf("%d requests failed and %d were handled, want %d in all", failed, handled, requests)
+			}
+
+			// The tolerance is several standard deviations of the failures of a rate of 0.3.
+			if got := float64(failed) / requests; math.Abs(got-tt.rate) > 0.03 {
+				t.Errorf("failure rate = %.3f, want about %.3f", got, tt.rate)
+			}
+		})
+	}
+}
@@ -1,31 +1,668 @@
 package service
 
 import (
+	"errors"
+	"sort"
+	"time"
+
 	"git.neds.sh/matty/entain/racing/db"
 	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type Racing interface {
 	// ListRaces will return a collection of races.
 	ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error)
+
+	// GetRace will return a single race.
+	GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error)
+
+	// GetRaceByMeetingAndNumber will return a single race by its meeting and number.
+	GetRaceByMeetingAndNumber(ctx context.Context, in *racing.GetRaceByMeetingAndNumberRequest) (*racing.Race, error)
+
+	// CreateRace will add a single race.
+	CreateRace(ctx context.Context, in *racing.CreateRaceRequest) (*racing.Race, error)
+
+	// CancelRace will mark a single race as cancelled.
+	CancelRace(ctx context.Context, in *racing.CancelRaceRequest) (*racing.Race, error)
+
+	// UpdateRace will update the masked fields of a single race.
+	UpdateRace(ctx context.Context, in *racing.UpdateRaceRequest) (*racing.Race, error)
+
+	// SetRaceResult will record the placings of a single race.
+	SetRaceResult(ctx context.Context, in *racing.SetRaceResultRequest) (*racing.RaceResult, error)
+
+	// GetRaceResult will return the result of a single race.
+	GetRaceResult(ctx context.Context, in *racing.GetRaceResultRequest) (*racing.RaceResult, error)
+
+	// ListMeetings will return a collection of meetings.
+	ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error)
+
+	// GetStatusSummary will return the number of races in each status.
+	GetStatusSummary(ctx context.Context, in *racing.GetStatusSummaryRequest) (*racing.StatusSummary, error)
+
+	// GetVisibilitySummary will return the number of visible and hidden races.
+	GetVisibilitySummary(ctx context.Context, in *racing.GetVisibilitySummaryRequest) (*racing.VisibilitySummary, error)
+
+	// ListRaceNumbers will return the distinct race numbers in use.
+	ListRaceNumbers(ctx context.Context, in *racing.ListRaceNumbersRequest) (*racing.ListRaceNumbersResponse, error)
+
+	// ListStartTimeClashes will return the start times shared by races of different meetings.
+	ListStartTimeClashes(ctx context.Context, in *racing.ListStartTimeClashesRequest) (*racing.ListStartTimeClashesResponse, error)
+
+	// GetDataQualityReport will return the number of races with each kind of data defect.
+	GetDataQualityReport(ctx context.Context, in *racing.GetDataQualityReportRequest) (*racing.DataQualityReport, error)
+
+	// RaceTimeline will return the races grouped into buckets by their start time.
+	RaceTimeline(ctx context.Context, in *racing.RaceTimelineRequest) (*racing.RaceTimelineResponse, error)
+
+	// PurgeRaces will remove the races that started long enough ago.
+	PurgeRaces(ctx context.Context, in *racing.PurgeRacesRequest) (*racing.PurgeRacesResponse, error)
+
+	// RaceHourlyHistogram will return the number of races starting in each hour of a day.
+	RaceHourlyHistogram(ctx context.Context, in *racing.RaceHourlyHistogramRequest) (*racing.RaceHourlyHistogramResponse, error)
+
+	// RaceOpenClosedTrend will return the number of races open and closed at intervals of a day.
+	RaceOpenClosedTrend(ctx context.Context, in *racing.RaceOpenClosedTrendRequest) (*racing.RaceOpenClosedTrendResponse, error)
+
+	// WatchRaces will stream races as their statuses change.
+	WatchRaces(in *racing.WatchRacesRequest, stream racing.Racing_WatchRacesServer) error
 }
 
+const (
+	// defaultPageSize is the most races listed when the filter doesn't ask for a limit.
+	defaultPageSize = 100
+
+	// maxPageSize is the most races listed, whatever the limit the filter asks for.
+	maxPageSize = 1000
+
+	// defaultBucketMinutes is the width of race timeline buckets when none is asked for.
+	defaultBucketMinutes = 15
+
+	// defaultWatchInterval is how often watched races are checked for status changes when no
+	// interval is asked for.
+	defaultWatchInterval = 5 * time.Second
+)
+
 // racingService implements the Racing interface.
 type racingService struct {
-	racesRepo db.RacesRepo
+	racesRepo    db.RacesRepo
+	meetingsRepo db.MeetingsRepo
+	admin        bool
+	maxIDs       int
+	// defaultVisible is whether races created without a visibility are visible.
+	defaultVisible bool
+	// maxSpan is the longest a filter's start time window may be, or 0 for no limit.
+	maxSpan time.Duration
 }
 
-// NewRacingService instantiates and returns a new racingService.
-func NewRacingService(racesRepo db.RacesRepo) Racing {
-	return &racingService{racesRepo}
+// NewRacingService instantiates and returns a new racingService. Admin-only reports are
+// rejected unless admin is set, and requests listing more than maxIDs IDs are rejected unless
+// maxIDs is 0, as are those filtering start times over a window longer than maxSpan unless it's
+// 0. Races created without a visibility are visible when defaultVisible is set.
+func NewRacingService(racesRepo db.RacesRepo, meetingsRepo db.MeetingsRepo, admin bool, maxIDs int, defaultVisible bool, maxSpan time.Duration) Racing {
+	return &racingService{racesRepo, meetingsRepo, admin, maxIDs, defaultVisible, maxSpan}
 }
 
 func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
-	races, err := s.racesRepo.List(in.Filter)
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	if in.Filter == nil {
+		in.Filter = &racing.ListRacesRequestFilter{}
+	}
+
+	in.Filter.Limit = pageSize(in.Filter.Limit)
+
+	// Tokens are validated along with the rest of the request.
+	if in.PageToken != "" {
+		in.Filter.Offset, _ = racing.DecodePageToken(in.PageToken)
+	}
+
+	resp := &racing.ListRacesResponse{}
+
+	if in.Snapshot {
+		if err := s.pinSnapshot(ctx, in.Filter); err != nil {
+			return nil, err
+		}
+
+		resp.SnapshotId, resp.AsOf = in.Filter.SnapshotId, in.Filter.AsOf
+	}
+
+	total, err := s.racesRepo.Count(ctx, in.Filter)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	resp.Total = total
+
+	if in.CountOnly {
+		return resp, nil
+	}
+
+	// Races past this page are left for the next.
+	if next := in.Filter.Offset + in.Filter.Limit; next < total {
+		resp.NextPageToken = racing.EncodePageToken(next)
+	}
+
+	if in.IdsOnly {
+		ids, err := s.racesRepo.ListIDs(ctx, in.Filter)
+		if err != nil {
+			return nil, repoError(err)
+		}
+
+		resp.Ids = ids
+
+		return resp, nil
+	}
+
+	races, err := s.racesRepo.List(ctx, in.Filter)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	resp.Races = races
+
+	return resp, nil
+}
+
+// pinSnapshot starts a snapshot the filter doesn't already continue, pinning it to the races
+// inserted so far and to now.
+func (s *racingService) pinSnapshot(ctx context.Context, filter *racing.ListRacesRequestFilter) error {
+	if filter.SnapshotId == 0 {
+		id, err := s.racesRepo.MaxID(ctx)
+		if err != nil {
+			return repoError(err)
+		}
+
+		filter.SnapshotId = id
+	}
+
+	if filter.AsOf == nil {
+		asOf, err := ptypes.TimestampProto(time.Now())
+		if err != nil {
+			return err
+		}
+
+		filter.AsOf = asOf
+	}
+
+	return nil
+}
+
+func (s *racingService) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.Race, error) {
+	race, err := s.racesRepo.Get(ctx, in.Id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if race == nil {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.Id)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) GetRaceByMeetingAndNumber(ctx context.Context, in *racing.GetRaceByMeetingAndNumberRequest) (*racing.Race, error) {
+	race, err := s.racesRepo.GetByMeetingAndNumber(ctx, in.MeetingId, in.Number)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if race == nil {
+		return nil, status.Errorf(codes.NotFound, "race %d of meeting %d not found", in.Number, in.MeetingId)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) CreateRace(ctx context.Context, in *racing.CreateRaceRequest) (*racing.Race, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "creating races requires admin mode")
+	}
+
+	// A hidden race can't be told apart from one without a visibility, unless it's given one.
+	race := proto.Clone(in.Race).(*racing.Race)
+	switch {
+	case in.Visible != nil:
+		race.Visible = in.Visible.Value
+	case !race.Visible:
+		race.Visible = s.defaultVisible
+	}
+
+	race, err := s.racesRepo.Create(ctx, race)
+	if errors.Is(err, db.ErrMeetingNotFound) {
+		return nil, status.Errorf(codes.FailedPrecondition, "meeting %d not found", in.Race.MeetingId)
+	}
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) CancelRace(ctx context.Context, in *racing.CancelRaceRequest) (*racing.Race, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "cancelling races requires admin mode")
+	}
+
+	race, err := s.racesRepo.Cancel(ctx, in.Id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if race == nil {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.Id)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) UpdateRace(ctx context.Context, in *racing.UpdateRaceRequest) (*racing.Race, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "updating races requires admin mode")
+	}
+
+	race, err := s.racesRepo.Update(ctx, in.Race, in.UpdateMask.Paths)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if race == nil {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.Race.Id)
+	}
+
+	return race, nil
+}
+
+func (s *racingService) SetRaceResult(ctx context.Context, in *racing.SetRaceResultRequest) (*racing.RaceResult, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "recording race results requires admin mode")
+	}
+
+	result, err := s.racesRepo.SetResult(ctx, in.RaceId, in.Placings)
+	if errors.Is(err, db.ErrRaceNotRun) {
+		return nil, status.Errorf(codes.FailedPrecondition, "race %d was cancelled or hasn't started", in.RaceId)
+	}
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if result == nil {
+		return nil, status.Errorf(codes.NotFound, "race %d not found", in.RaceId)
+	}
+
+	return result, nil
+}
+
+func (s *racingService) GetRaceResult(ctx context.Context, in *racing.GetRaceResultRequest) (*racing.RaceResult, error) {
+	result, err := s.racesRepo.GetResult(ctx, in.RaceId)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	if result == nil {
+		return nil, status.Errorf(codes.NotFound, "no result recorded for race %d", in.RaceId)
+	}
+
+	return result, nil
+}
+
+func (s *racingService) ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error) {
+	meetings, err := s.meetingsRepo.List(ctx)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.ListMeetingsResponse{Meetings: meetings}, nil
+}
+
+func (s *racingService) GetStatusSummary(ctx context.Context, in *racing.GetStatusSummaryRequest) (*racing.StatusSummary, error) {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	summary, err := s.racesRepo.StatusSummary(ctx, in.Filter)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return summary, nil
+}
+
+func (s *racingService) GetVisibilitySummary(ctx context.Context, in *racing.GetVisibilitySummaryRequest) (*racing.VisibilitySummary, error) {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	summary, err := s.racesRepo.VisibilitySummary(ctx, in.Filter)
 	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return summary, nil
+}
+
+func (s *racingService) ListRaceNumbers(ctx context.Context, in *racing.ListRaceNumbersRequest) (*racing.ListRaceNumbersResponse, error) {
+	if err := s.validateIDCount("meeting_ids", len(in.MeetingIds)); err != nil {
 		return nil, err
 	}
 
-	return &racing.ListRacesResponse{Races: races}, nil
+	numbers, err := s.racesRepo.ListNumbers(ctx, in.MeetingIds)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.ListRaceNumbersResponse{Numbers: numbers}, nil
+}
+
+func (s *racingService) ListStartTimeClashes(ctx context.Context, in *racing.ListStartTimeClashesRequest) (*racing.ListStartTimeClashesResponse, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "start time clashes report requires admin mode")
+	}
+
+	clashes, err := s.racesRepo.StartTimeClashes(ctx)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.ListStartTimeClashesResponse{Clashes: clashes}, nil
+}
+
+func (s *racingService) GetDataQualityReport(ctx context.Context, in *racing.GetDataQualityReportRequest) (*racing.DataQualityReport, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "data quality report requires admin mode")
+	}
+
+	report, err := s.racesRepo.DataQuality(ctx)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return report, nil
+}
+
+func (s *racingService) RaceTimeline(ctx context.Context, in *racing.RaceTimelineRequest) (*racing.RaceTimelineResponse, error) {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	minutes := in.BucketMinutes
+	if minutes == 0 {
+		minutes = defaultBucketMinutes
+	}
+
+	buckets, err := s.racesRepo.Timeline(ctx, in.Filter, time.Duration(minutes)*time.Minute)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.RaceTimelineResponse{Buckets: buckets}, nil
+}
+
+func (s *racingService) PurgeRaces(ctx context.Context, in *racing.PurgeRacesRequest) (*racing.PurgeRacesResponse, error) {
+	if !s.admin {
+		return nil, status.Error(codes.PermissionDenied, "purging races requires admin mode")
+	}
+
+	purged, err := s.racesRepo.Purge(ctx, time.Now().AddDate(0, 0, -int(in.OlderThanDays)), in.DryRun)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.PurgeRacesResponse{Purged: purged}, nil
+}
+
+func (s *racingService) RaceHourlyHistogram(ctx context.Context, in *racing.RaceHourlyHistogramRequest) (*racing.RaceHourlyHistogramResponse, error) {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	var location *time.Location
+	if in.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(in.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	hours, err := s.racesRepo.HourlyHistogram(ctx, in.Filter, in.Date, location)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.RaceHourlyHistogramResponse{Hours: hours}, nil
+}
+
+func (s *racingService) RaceOpenClosedTrend(ctx context.Context, in *racing.RaceOpenClosedTrendRequest) (*racing.RaceOpenClosedTrendResponse, error) {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return nil, status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return nil, err
+	}
+
+	minutes := in.BucketMinutes
+	if minutes == 0 {
+		minutes = defaultBucketMinutes
+	}
+
+	var location *time.Location
+	if in.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(in.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	points, err := s.racesRepo.OpenClosedTrend(ctx, in.Filter, in.Date, time.Duration(minutes)*time.Minute, location)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	return &racing.RaceOpenClosedTrendResponse{Points: points}, nil
+}
+
+func (s *racingService) WatchRaces(in *racing.WatchRacesRequest, stream racing.Racing_WatchRacesServer) error {
+	if !s.admin && requiresAdmin(in.Filter) {
+		return status.Error(codes.PermissionDenied, "filter requires admin mode")
+	}
+
+	if err := s.validateFilter(in.Filter); err != nil {
+		return err
+	}
+
+	if in.Filter == nil {
+		in.Filter = &racing.ListRacesRequestFilter{}
+	}
+
+	// Every matching race is watched, unless the filter asks for a limit.
+	if in.Filter.Limit > 0 {
+		in.Filter.Limit = pageSize(in.Filter.Limit)
+	}
+
+	interval := defaultWatchInterval
+	if in.IntervalSeconds > 0 {
+		interval = time.Duration(in.IntervalSeconds) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Each race streamed, as last streamed, so changes to its status can be told.
+	streamed := make(map[int64]*racing.Race)
+
+	for {
+		if err := s.streamChanges(stream, in.Filter, streamed); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// streamChanges streams the races matching the filter that haven't been streamed yet, or were
+// streamed with another status, then those streamed that no longer match it as removed.
+func (s *racingService) streamChanges(stream racing.Racing_WatchRacesServer, filter *racing.ListRacesRequestFilter, streamed map[int64]*racing.Race) error {
+	ctx := stream.Context()
+
+	races, err := s.racesRepo.List(ctx, filter)
+	if err != nil {
+		return repoError(err)
+	}
+
+	matched := make(map[int64]bool, len(races))
+
+	for _, race := range races {
+		matched[race.Id] = true
+
+		previous, ok := streamed[race.Id]
+		if ok && previous.Status == race.Status {
+			continue
+		}
+
+		update := &racing.WatchRacesResponse{Race: race}
+		if ok {
+			update.PreviousStatus = previous.Status
+		}
+
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+
+		streamed[race.Id] = race
+	}
+
+	var unmatched []int64
+	for id := range streamed {
+		if !matched[id] {
+			unmatched = append(unmatched, id)
+		}
+	}
+
+	if len(unmatched) == 0 {
+		return nil
+	}
+
+	sort.Slice(unmatched, func(i, j int) bool { return unmatched[i] < unmatched[j] })
+
+	// Races that stopped matching are looked up by ID alone, so they're streamed as they are
+	// now, such as cancelled, whatever the rest of the filter.
+	current, err := s.racesRepo.List(ctx, &racing.ListRacesRequestFilter{Ids: unmatched, IncludeCancelled: true})
+	if err != nil {
+		return repoError(err)
+	}
+
+	found := make(map[int64]*racing.Race, len(current))
+	for _, race := range current {
+		found[race.Id] = race
+	}
+
+	for _, id := range unmatched {
+		update := &racing.WatchRacesResponse{Race: found[id], PreviousStatus: streamed[id].Status, Removed: true}
+
+		// Purged races are no longer found, so they're streamed as they were last.
+		if update.Race == nil {
+			update.Race = streamed[id]
+		}
+
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+
+		delete(streamed, id)
+	}
+
+	return nil
+}
+
+// requiresAdmin reports whether the filter requests any admin-only reports.
+func requiresAdmin(filter *racing.ListRacesRequestFilter) bool {
+	return filter.GetVisibleInHiddenMeeting() || filter.GetVisibilityMismatch() || filter.GetOrphansOnly() || filter.GetInvalidNumber()
+}
+
+// validateFilter returns an InvalidArgument error if the filter lists more IDs than allowed, as
+// each is bound as a query parameter, or spans more time between its start times than allowed.
+func (s *racingService) validateFilter(filter *racing.ListRacesRequestFilter) error {
+	if err := s.validateIDCount("ids", len(filter.GetIds())); err != nil {
+		return err
+	}
+
+	if err := s.validateIDCount("meeting_ids", len(filter.GetMeetingIds())); err != nil {
+		return err
+	}
+
+	// Windows open at either end aren't capped, as other filters such as statuses bound them.
+	if s.maxSpan > 0 && filter.GetStartTimeAfter() != nil && filter.GetStartTimeBefore() != nil {
+		if span := filter.StartTimeBefore.AsTime().Sub(filter.StartTimeAfter.AsTime()); span > s.maxSpan {
+			return status.Errorf(codes.InvalidArgument, "start_time_after to start_time_before spans %s, exceeding the maximum of %s", span, s.maxSpan)
+		}
+	}
+
+	return nil
+}
+
+func (s *racingService) validateIDCount(field string, n int) error {
+	if s.maxIDs > 0 && n > s.maxIDs {
+		return status.Errorf(codes.InvalidArgument, "too many %s: %d exceeds the maximum of %d", field, n, s.maxIDs)
+	}
+
+	return nil
+}
+
+// pageSize returns the number of races to list for the requested limit.
+func pageSize(limit int64) int64 {
+	switch {
+	case limit <= 0:
+		return defaultPageSize
+	case limit > maxPageSize:
+		return maxPageSize
+	default:
+		return limit
+	}
+}
+
+// repoError translates errors from the races repository into their gRPC status, where one
+// applies.
+func repoError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrTooManyQueries):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return err
 }
@@ -0,0 +1,559 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestRepos returns the races and meetings repositories over a new database in a temporary
+// directory, with meetings seeded, holding just the given races.
+func newTestRepos(t *testing.T, races ...*racing.Race) (db.RacesRepo, db.MeetingsRepo) {
+	t.Helper()
+
+	racingDB := dbtest.Open(t, db.DriverName)
+
+	racesRepo := db.NewRacesRepo(racingDB, nil)
+	dbtest.Load(t, racingDB, racesRepo, races...)
+
+	return racesRepo, db.NewMeetingsRepo(racingDB, nil)
+}
+
+func TestCancelRace(t *testing.T) {
+	tests := []struct {
+		name     string
+		admin    bool
+		id       int64
+		wantCode codes.Code
+	}{
+		{name: "cancels the race", admin: true, id: 1, wantCode: codes.OK},
+		{name: "requires admin mode", admin: false, id: 1, wantCode: codes.PermissionDenied},
+		{name: "unknown race", admin: true, id: 2, wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo, meetingsRepo := newTestRepos(t, dbtest.NewRace(t, 1, 1, 1, time.Now().Add(time.Hour)))
+			svc := NewRacingService(racesRepo, meetingsRepo, tt.admin, 0, false, 0)
+
+			race, err := svc.CancelRace(context.Background(), &racing.CancelRaceRequest{Id: tt.id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("CancelRace() code = %v, want %v", code, tt.wantCode)
+			}
+
+			want := racing.RaceStatus_OPEN
+			if tt.wantCode == codes.OK {
+				want = racing.RaceStatus_CANCELLED
+
+				if race.Status != want {
+					t.Errorf("CancelRace() status = %v, want %v", race.Status, want)
+				}
+			}
+
+			stored, err := svc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stored.Status != want {
+				t.Errorf("GetRace() status after CancelRace() = %v, want %v", stored.Status, want)
+			}
+		})
+	}
+}
+
+// busyRacesRepo is a races repository whose every list and fetch is rejected by its query
+// limiter.
+type busyRacesRepo struct {
+	db.RacesRepo
+}
+
+func (busyRacesRepo) Count(context.Context, *racing.ListRacesRequestFilter) (int64, error) {
+	return 0, db.ErrTooManyQueries
+}
+
+func (busyRacesRepo) Get(context.Context, int64) (*racing.Race, error) {
+	return nil, db.ErrTooManyQueries
+}
+
+func TestQueriesBeyondLimitAreResourceExhausted(t *testing.T) {
+	svc := NewRacingService(busyRacesRepo{}, nil, false, 0, false, 0)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "ListRaces",
+			call: func() error {
+				_, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{})
+				return err
+			},
+		},
+		{
+			name: "GetRace",
+			call: func() error {
+				_, err := svc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 1})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != codes.ResourceExhausted {
+				t.Errorf("%s() code = %v, want %v", tt.name, code, codes.ResourceExhausted)
+			}
+		})
+	}
+}
+
+func TestListRacesSnapshotPagesAreStable(t *testing.T) {
+	now := time.Now()
+
+	var races []*racing.Race
+	for i := int64(1); i <= 10; i++ {
+		races = append(races, dbtest.NewRace(t, i, 1, i, now.Add(time.Hour+time.Duration(i)*time.Minute)))
+	}
+
+	racesRepo, meetingsRepo := newTestRepos(t, races...)
+	svc := NewRacingService(racesRepo, meetingsRepo, false, 0, false, 0)
+
+	var (
+		seen  = make(map[int64]int)
+		req   = &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{Limit: 3}, Snapshot: true}
+		pages int
+	)
+
+	for {
+		resp, err := svc.ListRaces(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, race := range resp.Races {
+			seen[race.Id]++
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+
+		// Races inserted between pages start before every race listed so far, so they would
+		// shift the later pages were they included.
+		if err := racesRepo.InsertBatch(context.Background(), []*racing.Race{dbtest.NewRace(t, 0, 1, 20, now.Add(time.Minute))}); err != nil {
+			t.Fatal(err)
+		}
+
+		req.Filter.SnapshotId, req.Filter.AsOf, req.PageToken = resp.SnapshotId, resp.AsOf, resp.NextPageToken
+		pages++
+	}
+
+	if pages != 3 {
+		t.Errorf("ListRaces() listed %d pages after the first, want 3", pages)
+	}
+
+	for _, race := range races {
+		if seen[race.Id] != 1 {
+			t.Errorf("race %d listed %d times, want once", race.Id, seen[race.Id])
+		}
+	}
+
+	if len(seen) != len(races) {
+		t.Errorf("ListRaces() listed %d races, want the %d in the snapshot", len(seen), len(races))
+	}
+}
+
+// manyRaces returns n races starting a minute apart from an hour from now.
+func manyRaces(t *testing.T, n int) []*racing.Race {
+	t.Helper()
+
+	start := time.Now().Add(time.Hour)
+
+	starts := make([]time.Time, n)
+	for i := range starts {
+		starts[i] = start.Add(time.Duration(i) * time.Minute)
+	}
+
+	return dbtest.NewRaces(t, starts...)
+}
+
+func TestListRacesPageSize(t *testing.T) {
+	const races = maxPageSize + 100
+
+	racesRepo, meetingsRepo := newTestRepos(t, manyRaces(t, races)...)
+	svc := NewRacingService(racesRepo, meetingsRepo, false, 0, false, 0)
+
+	tests := []struct {
+		name  string
+		limit int64
+		want  int
+	}{
+		{name: "default", limit: 0, want: defaultPageSize},
+		{name: "requested", limit: 5, want: 5},
+		{name: "capped", limit: races, want: maxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{Limit: tt.limit}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Races) != tt.want {
+				t.Errorf("ListRaces() listed %d races, want %d", len(resp.Races), tt.want)
+			}
+
+			// The races past the page are left for the next, rather than dropped.
+			if resp.Total != races || resp.NextPageToken == "" {
+				t.Errorf("ListRaces() total = %d, next page token %q, want %d and a token", resp.Total, resp.NextPageToken, races)
+			}
+		})
+	}
+}
+
+func TestListRacesIDsOnly(t *testing.T) {
+	racesRepo, meetingsRepo := newTestRepos(t, manyRaces(t, 5)...)
+	svc := NewRacingService(racesRepo, meetingsRepo, false, 0, false, 0)
+
+	filter := &racing.ListRacesRequestFilter{OrderDirection: "DESC", Limit: 3}
+
+	full, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: proto.Clone(filter).(*racing.ListRacesRequestFilter)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: filter, IdsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []int64
+	for _, race := range full.Races {
+		want = append(want, race.Id)
+	}
+
+	if !reflect.DeepEqual(resp.Ids, want) {
+		t.Errorf("ListRaces() IDs only = %v, want %v as listed", resp.Ids, want)
+	}
+
+	// Only the IDs are listed, along with the total and the next page.
+	if len(resp.Races) != 0 || resp.Total != full.Total || resp.NextPageToken != full.NextPageToken {
+		t.Errorf("ListRaces() IDs only = %d races, total %d, next page token %q, want no races, total %d, next page token %q", len(resp.Races), resp.Total, resp.NextPageToken, full.Total, full.NextPageToken)
+	}
+}
+
+// countingRacesRepo is a races repository recording how many times races are listed.
+type countingRacesRepo struct {
+	db.RacesRepo
+	lists int
+}
+
+func (r *countingRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]*racing.Race, error) {
+	r.lists++
+	return r.RacesRepo.List(ctx, filter)
+}
+
+func (r *countingRacesRepo) ListIDs(ctx context.Context, filter *racing.ListRacesRequestFilter) ([]int64, error) {
+	r.lists++
+	return r.RacesRepo.ListIDs(ctx, filter)
+}
+
+func TestListRacesCountOnly(t *testing.T) {
+	racesRepo, meetingsRepo := newTestRepos(t, manyRaces(t, 5)...)
+	counting := &countingRacesRepo{RacesRepo: racesRepo}
+	svc := NewRacingService(counting, meetingsRepo, false, 0, false, 0)
+
+	tests := []struct {
+		name string
+		req  *racing.ListRacesRequest
+	}{
+		{name: "count only", req: &racing.ListRacesRequest{CountOnly: true}},
+		{name: "with a limit", req: &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{Limit: 2}, CountOnly: true}},
+		{name: "with IDs only", req: &racing.ListRacesRequest{CountOnly: true, IdsOnly: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counting.lists = 0
+
+			resp, err := svc.ListRaces(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Total != 5 || len(resp.Races) != 0 || len(resp.Ids) != 0 {
+				t.Errorf("ListRaces() = total %d, %d races and %d IDs, want total 5 and none listed", resp.Total, len(resp.Races), len(resp.Ids))
+			}
+
+			// The races aren't even fetched.
+			if counting.lists != 0 {
+				t.Errorf("ListRaces() listed races %d times, want 0", counting.lists)
+			}
+		})
+	}
+}
+
+// watchedStream is a WatchRaces stream recording the updates sent, which is done once want
+// updates have been.
+type watchedStream struct {
+	racing.Racing_WatchRacesServer
+	ctx    context.Context
+	cancel context.CancelFunc
+	want   int
+	sent   []*racing.WatchRacesResponse
+}
+
+func (s *watchedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchedStream) Send(update *racing.WatchRacesResponse) error {
+	s.sent = append(s.sent, update)
+	if len(s.sent) == s.want {
+		s.cancel()
+	}
+
+	return nil
+}
+
+func TestWatchRacesWatchesEveryRace(t *testing.T) {
+	const races = defaultPageSize + 50
+
+	racesRepo, meetingsRepo := newTestRepos(t, manyRaces(t, races)...)
+	svc := NewRacingService(racesRepo, meetingsRepo, false, 0, false, 0)
+
+	tests := []struct {
+		name  string
+		limit int64
+		want  int
+	}{
+		{name: "every race", limit: 0, want: races},
+		{name: "requested", limit: 5, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Streams sending fewer updates than wanted are given up on after a while.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			stream := &watchedStream{ctx: ctx, cancel: cancel, want: tt.want}
+
+			err := svc.WatchRaces(&racing.WatchRacesRequest{
+				Filter:          &racing.ListRacesRequestFilter{Limit: tt.limit},
+				IntervalSeconds: 3600,
+			}, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stream.sent) != tt.want {
+				t.Errorf("WatchRaces() streamed %d races, want %d", len(stream.sent), tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRaceOnlyUpdatesMaskedFields(t *testing.T) {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	updatedStart, _ := ptypes.TimestampProto(start.Add(time.Hour))
+
+	// The update sets every field, but only those masked may change.
+	update := &racing.Race{Id: 1, MeetingId: 2, Name: "Renamed", Number: 7, Visible: false, AdvertisedStartTime: updatedStart}
+
+	tests := []struct {
+		name  string
+		paths []string
+		apply func(race *racing.Race)
+	}{
+		{
+			name:  "name",
+			paths: []string{"name"},
+			apply: func(race *racing.Race) { race.Name = update.Name },
+		},
+		{
+			name:  "number and visibility",
+			paths: []string{"number", "visible"},
+			apply: func(race *racing.Race) { race.Number, race.Visible = update.Number, update.Visible },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo, meetingsRepo := newTestRepos(t, dbtest.NewRace(t, 1, 1, 3, start))
+			svc := NewRacingService(racesRepo, meetingsRepo, true, 0, false, 0)
+
+			want, err := svc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.apply(want)
+
+			updated, err := svc.UpdateRace(context.Background(), &racing.UpdateRaceRequest{
+				Race:       update,
+				UpdateMask: &field_mask.FieldMask{Paths: tt.paths},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := svc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !proto.Equal(got, want) {
+				t.Errorf("GetRace() after UpdateRace() = %v, want %v", got, want)
+			}
+
+			if !proto.Equal(updated, want) {
+				t.Errorf("UpdateRace() = %v, want %v", updated, want)
+			}
+		})
+	}
+}
+
+func TestCreateRaceDefaultVisibility(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultVisible bool
+		raceVisible    bool
+		visible        *wrappers.BoolValue
+		want           bool
+	}{
+		{name: "omitted, hidden by default", want: false},
+		{name: "omitted, visible by default", defaultVisible: true, want: true},
+		{name: "race visible", raceVisible: true, want: true},
+		{name: "explicitly hidden, visible by default", defaultVisible: true, visible: &wrappers.BoolValue{Value: false}, want: false},
+		{name: "explicitly visible, hidden by default", visible: &wrappers.BoolValue{Value: true}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racesRepo, meetingsRepo := newTestRepos(t)
+			svc := NewRacingService(racesRepo, meetingsRepo, true, 0, tt.defaultVisible, 0)
+
+			race := dbtest.NewRace(t, 0, 1, 1, time.Now().Add(time.Hour))
+			race.Visible = tt.raceVisible
+
+			created, err := svc.CreateRace(context.Background(), &racing.CreateRaceRequest{Race: race, Visible: tt.visible})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := racesRepo.Get(context.Background(), created.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stored.Visible != tt.want {
+				t.Errorf("stored visible = %t, want %t", stored.Visible, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartTimeSpanIsCapped(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	racesRepo, meetingsRepo := newTestRepos(t, dbtest.NewRace(t, 1, 1, 1, start.Add(time.Hour)))
+	svc := NewRacingService(racesRepo, meetingsRepo, false, 0, false, 24*time.Hour)
+
+	tests := []struct {
+		name     string
+		span     time.Duration
+		wantCode codes.Code
+	}{
+		{name: "under the cap", span: 12 * time.Hour, wantCode: codes.OK},
+		{name: "at the cap", span: 24 * time.Hour, wantCode: codes.OK},
+		{name: "over the cap", span: 24*time.Hour + time.Second, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, _ := ptypes.TimestampProto(start)
+			before, _ := ptypes.TimestampProto(start.Add(tt.span))
+			filter := &racing.ListRacesRequestFilter{StartTimeAfter: after, StartTimeBefore: before}
+
+			resp, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: filter})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("ListRaces() code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if err == nil && len(resp.Races) != 1 {
+				t.Errorf("ListRaces() races = %d, want 1", len(resp.Races))
+			}
+
+			// Every RPC taking a filter caps its span the same way.
+			if _, err := svc.GetStatusSummary(context.Background(), &racing.GetStatusSummaryRequest{Filter: filter}); status.Code(err) != tt.wantCode {
+				t.Errorf("GetStatusSummary() code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+		})
+	}
+
+	// A window open at one end isn't capped.
+	after, _ := ptypes.TimestampProto(start.AddDate(-10, 0, 0))
+	if _, err := svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{StartTimeAfter: after}}); err != nil {
+		t.Errorf("ListRaces() with an open window error = %v", err)
+	}
+}
+
+func TestRequestedIDsAreCapped(t *testing.T) {
+	const maxIDs = 100
+
+	racesRepo, meetingsRepo := newTestRepos(t, manyRaces(t, 5)...)
+	capped := NewRacingService(racesRepo, meetingsRepo, false, maxIDs, false, 0)
+
+	// ids returns the IDs 1 to n.
+	ids := func(n int) []int64 {
+		ids := make([]int64, n)
+		for i := range ids {
+			ids[i] = int64(i + 1)
+		}
+
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		svc      Racing
+		ids      []int64
+		wantCode codes.Code
+	}{
+		{name: "at the cap", svc: capped, ids: ids(maxIDs), wantCode: codes.OK},
+		{name: "over the cap", svc: capped, ids: ids(maxIDs + 1), wantCode: codes.InvalidArgument},
+		{name: "far over the cap", svc: capped, ids: ids(10000), wantCode: codes.InvalidArgument},
+		{name: "uncapped", svc: NewRacingService(racesRepo, meetingsRepo, false, 0, false, 0), ids: ids(10000), wantCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{Ids: tt.ids}})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("ListRaces() code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if err == nil && len(resp.Races) != 5 {
+				t.Errorf("ListRaces() races = %d, want 5", len(resp.Races))
+			}
+
+			// Meeting IDs are capped the same way.
+			if _, err := tt.svc.ListRaces(context.Background(), &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: tt.ids}}); status.Code(err) != tt.wantCode {
+				t.Errorf("ListRaces() by meeting code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+			if _, err := tt.svc.ListRaceNumbers(context.Background(), &racing.ListRaceNumbersRequest{MeetingIds: tt.ids}); status.Code(err) != tt.wantCode {
+				t.Errorf("ListRaceNumbers() code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+		})
+	}
+}
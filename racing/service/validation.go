@@ -0,0 +1,53 @@
+package service
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// validator is implemented by requests that can check themselves for malformed fields.
+type validator interface {
+	Validate() error
+}
+
+// NewValidator returns an interceptor rejecting malformed requests before they're handled,
+// with the InvalidArgument error returned by their Validate method. Requests without one are
+// handled as usual.
+func NewValidator() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if v, ok := req.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewStreamValidator returns an interceptor rejecting malformed requests of streaming RPCs, as
+// NewValidator does for unary RPCs, as they're received.
+func NewStreamValidator() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{stream})
+	}
+}
+
+// validatingStream validates each request received on the stream.
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+// RecvMsg receives the next request, returning the error of its Validate method instead if it's
+// malformed.
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if v, ok := m.(validator); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
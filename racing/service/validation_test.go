@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidatorRejectsBeforeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      interface{}
+		wantCode codes.Code
+	}{
+		{
+			name:     "valid list",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{OrderDirection: "desc"}},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "unknown order direction falls back to ascending",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{OrderDirection: "UP"}},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "mutually exclusive fields",
+			req:      &racing.ListRacesRequest{IdsOnly: true, CountOnly: true},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "repeated sort field",
+			req: &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{SortBy: []*racing.OrderBy{
+				{Field: racing.OrderField_NAME},
+				{Field: racing.OrderField_NAME, Direction: racing.OrderDirection_DESC},
+			}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "malformed name_regexp",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{NameRegexp: "Cup("}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "valid name_regexp",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{NameRegexp: "^(Melbourne|Cox) "}},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "unknown sort_preset",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{SortPreset: 99}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "sort_preset with order_direction",
+			req:      &racing.ListRacesRequest{Filter: &racing.ListRacesRequestFilter{SortPreset: racing.SortPreset_NEWEST, OrderDirection: "DESC"}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "result without placings",
+			req:      &racing.SetRaceResultRequest{RaceId: 1},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "request without Validate",
+			req:      &racing.GetRaceRequest{Id: 1},
+			wantCode: codes.OK,
+		},
+	}
+
+	interceptor := NewValidator()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled bool
+
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				handled = true
+				return nil, nil
+			}
+
+			_, err := interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/Test"}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("interceptor code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+
+			if handled != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called = %t, want %t", handled, tt.wantCode == codes.OK)
+			}
+		})
+	}
+}
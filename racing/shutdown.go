@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serve serves gRPC on the listener, along with the health service reporting it serving, until
+// the context is done. It then reports it not serving, stops accepting RPCs and lets those in
+// flight finish, before closing the repository. RPCs still in flight after the timeout, such as
+// streams, are cancelled.
+func serve(ctx context.Context, grpcServer *grpc.Server, conn net.Listener, repo io.Closer, timeout time.Duration) error {
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	served := make(chan error, 1)
+	go func() { served <- grpcServer.Serve(conn) }()
+
+	select {
+	case err := <-served:
+		repo.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("gRPC server shutting down\n")
+
+	// Load balancers stop sending RPCs once the server's no longer healthy.
+	healthServer.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		grpcServer.Stop()
+		<-stopped
+	}
+
+	if err := <-served; err != nil {
+		repo.Close()
+		return err
+	}
+
+	return repo.Close()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/db/dbtest"
+)
+
+func TestServeCancelsStreamsAfterTimeout(t *testing.T) {
+	racingDB := dbtest.Open(t, db.DriverName)
+	racesRepo := db.NewRacesRepo(racingDB, nil)
+	dbtest.Load(t, racingDB, racesRepo)
+
+	conn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, grpc.NewServer(), conn, racesRepo, 100*time.Millisecond) }()
+
+	client, err := grpc.Dial(conn.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Health watches stream until they're cancelled, as WatchRaces streams do.
+	watch, err := healthpb.NewHealthClient(client).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := watch.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health status = %v, want SERVING", update.Status)
+	}
+
+	shutdown()
+
+	// The server reports it's no longer serving, then cancels the stream once the timeout passes.
+	if update, err = watch.Recv(); err != nil || update.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("health status on shutdown = %v (%v), want NOT_SERVING", update.GetStatus(), err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve() didn't return after the shutdown timeout")
+	}
+
+	if _, err := watch.Recv(); err == nil {
+		t.Error("stream still open after shutdown")
+	}
+
+	if err := racingDB.Ping(); err == nil {
+		t.Error("database still open after shutdown")
+	}
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"time"
+
+	"syreclabs.com/go/faker"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// seededSport is a sport events are seeded for, with the competitions and venues its events are
+// given.
+type seededSport struct {
+	name         string
+	sportType    sports.SportType
+	competitions []string
+	venues       []string
+}
+
+// seededSports are the sports events are seeded for, in ID order.
+var seededSports = []seededSport{
+	{
+		name:         "Soccer",
+		sportType:    sports.SportType_TEAM,
+		competitions: []string{"A-League Men", "Premier League", "FIFA World Cup Qualifiers"},
+		venues:       []string{"AAMI Park", "Allianz Stadium", "Suncorp Stadium", "Old Trafford"},
+	},
+	{
+		name:         "Tennis",
+		sportType:    sports.SportType_INDIVIDUAL,
+		competitions: []string{"Australian Open", "Wimbledon", "US Open"},
+		venues:       []string{"Rod Laver Arena", "Centre Court", "Arthur Ashe Stadium"},
+	},
+	{
+		name:         "Basketball",
+		sportType:    sports.SportType_TEAM,
+		competitions: []string{"NBL", "NBA"},
+		venues:       []string{"John Cain Arena", "Qudos Bank Arena", "Madison Square Garden"},
+	},
+	{
+		name:         "Cricket",
+		sportType:    sports.SportType_TEAM,
+		competitions: []string{"Big Bash League", "Sheffield Shield", "Test Series"},
+		venues:       []string{"Melbourne Cricket Ground", "Sydney Cricket Ground", "Adelaide Oval", "The Gabba"},
+	},
+	{
+		name:         "Rugby League",
+		sportType:    sports.SportType_TEAM,
+		competitions: []string{"NRL", "State of Origin"},
+		venues:       []string{"Suncorp Stadium", "Accor Stadium", "4 Pines Park"},
+	},
+	{
+		name:         "Australian Rules",
+		sportType:    sports.SportType_TEAM,
+		competitions: []string{"AFL", "AFLW"},
+		venues:       []string{"Melbourne Cricket Ground", "Marvel Stadium", "Optus Stadium", "Adelaide Oval"},
+	},
+}
+
+func (r *eventsRepo) seed() error {
+	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS sports (id INTEGER PRIMARY KEY, name TEXT)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	for i, sport := range seededSports {
+		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO sports(id, name) VALUES (?,?)`)
+		if err == nil {
+			_, err = statement.Exec(i+1, sport.name)
+		}
+	}
+
+	statement, err = r.db.Prepare(`CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, sport_id INTEGER, name TEXT, visible INTEGER, advertised_start_time DATETIME)`)
+	if err == nil {
+		_, err = statement.Exec()
+	}
+
+	for i := 1; i <= 100; i++ {
+		sport := faker.RandomInt(0, len(seededSports)-1)
+
+		// Individuals are named as people, while teams are named as teams.
+		home, away := faker.Team().Name(), faker.Team().Name()
+		if seededSports[sport].sportType == sports.SportType_INDIVIDUAL {
+			home, away = faker.Name().Name(), faker.Name().Name()
+		}
+
+		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO events(id, sport_id, name, visible, advertised_start_time) VALUES (?,?,?,?,?)`)
+		if err == nil {
+			_, err = statement.Exec(
+				i,
+				sport+1,
+				home+" vs "+away,
+				faker.Number().Between(0, 1),
+				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
+			)
+		}
+	}
+
+	return err
+}
+
+// migrate brings the schema of an existing database up to date.
+func (r *eventsRepo) migrate() error {
+	if err := r.addColumn("sports", "type", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(getEventQueries()[eventsSeedSportTypes]); err != nil {
+		return err
+	}
+
+	for _, column := range []string{"competition", "home_team", "away_team", "venue"} {
+		if err := r.addColumn("events", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.db.Exec(getEventQueries()[eventsSeedDetails])
+
+	return err
+}
+
+// addColumn adds the column to the table, unless it already exists.
+func (r *eventsRepo) addColumn(table, column, definition string) error {
+	rows, err := r.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+
+	return err
+}
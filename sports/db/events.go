@@ -0,0 +1,282 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	_ "github.com/mattn/go-sqlite3"
+
+	"git.neds.sh/matty/entain/order"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// EventsRepo provides repository access to sports events.
+type EventsRepo interface {
+	// Init will initialise our events repository.
+	Init() error
+
+	// List will return a list of events.
+	List(filter *sports.ListEventsRequestFilter) ([]*sports.Event, error)
+
+	// Count will return the number of events matching the filter, regardless of its limit and offset.
+	Count(filter *sports.ListEventsRequestFilter) (int64, error)
+
+	// Get will return a single event by its ID, or nil if no such event exists.
+	Get(id int64) (*sports.Event, error)
+
+	// Close will close the database.
+	Close() error
+}
+
+type eventsRepo struct {
+	db   *sql.DB
+	init sync.Once
+}
+
+// NewEventsRepo creates a new events repository.
+func NewEventsRepo(db *sql.DB) EventsRepo {
+	return &eventsRepo{db: db}
+}
+
+// Close closes the database, waiting for queries already made to finish.
+func (r *eventsRepo) Close() error {
+	return r.db.Close()
+}
+
+// Init prepares the events repository dummy data.
+func (r *eventsRepo) Init() error {
+	var err error
+
+	r.init.Do(func() {
+		// For test/example purposes, we seed the DB with some dummy sports and events.
+		err = r.seed()
+		if err == nil {
+			err = r.migrate()
+		}
+	})
+
+	return err
+}
+
+// List returns the page of events matching the filter, ordered by their advertised start time.
+func (r *eventsRepo) List(filter *sports.ListEventsRequestFilter) ([]*sports.Event, error) {
+	query, args := r.applyFilter(getEventQueries()[eventsList], filter, statusTime(filter))
+	query = r.applyOrder(query, filter)
+	query, args = r.applyPage(query, args, filter)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanEvents(rows)
+}
+
+// Get returns the event with the given ID, with its status as at now, or nil if no such event
+// exists.
+func (r *eventsRepo) Get(id int64) (*sports.Event, error) {
+	rows, err := r.db.Query(getEventQueries()[eventsList]+" WHERE events.id = ?", time.Now().Format(time.RFC3339), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events, err := r.scanEvents(rows)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+
+	return events[0], nil
+}
+
+// Count returns the number of events matching the filter, regardless of its limit and offset.
+func (r *eventsRepo) Count(filter *sports.ListEventsRequestFilter) (int64, error) {
+	query, args := r.applyFilter(getEventQueries()[eventsList], filter, statusTime(filter))
+
+	var count int64
+
+	if err := r.db.QueryRow(fmt.Sprintf(getEventQueries()[eventsCount], query), args...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// applyFilter filters the events list query, with event statuses evaluated as at now.
+func (r *eventsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter, now time.Time) (string, []interface{}) {
+	var (
+		clauses []string
+		// The status selected by the list query is the first placeholder to bind.
+		args = []interface{}{now.Format(time.RFC3339)}
+	)
+
+	if filter == nil {
+		return query, args
+	}
+
+	if len(filter.SportIds) > 0 {
+		clauses = append(clauses, "events.sport_id IN ("+strings.Repeat("?,", len(filter.SportIds)-1)+"?)")
+
+		for _, sportID := range filter.SportIds {
+			args = append(args, sportID)
+		}
+	}
+
+	if len(filter.SportNames) > 0 {
+		clauses = append(clauses, "sports.name COLLATE NOCASE IN ("+strings.Repeat("?,", len(filter.SportNames)-1)+"?)")
+
+		for _, name := range filter.SportNames {
+			args = append(args, name)
+		}
+	}
+
+	if len(filter.SportTypes) > 0 {
+		clauses = append(clauses, "sports.type IN ("+strings.Repeat("?,", len(filter.SportTypes)-1)+"?)")
+
+		for _, sportType := range filter.SportTypes {
+			args = append(args, sportType)
+		}
+	}
+
+	if filter.VisibleOnly {
+		clauses = append(clauses, "events.visible = 1")
+	}
+
+	if filter.StartTimeAfter != nil {
+		clauses = append(clauses, "datetime(events.advertised_start_time) > datetime(?)")
+		args = append(args, filter.StartTimeAfter.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.StartTimeBefore != nil {
+		clauses = append(clauses, "datetime(events.advertised_start_time) < datetime(?)")
+		args = append(args, filter.StartTimeBefore.AsTime().Format(time.RFC3339))
+	}
+
+	if filter.ClosingWithinSeconds > 0 {
+		clauses = append(clauses, "datetime(events.advertised_start_time) > datetime(?) AND datetime(events.advertised_start_time) <= datetime(?)")
+		args = append(args, now.Format(time.RFC3339), now.Add(time.Duration(filter.ClosingWithinSeconds)*time.Second).Format(time.RFC3339))
+	}
+
+	if filter.NextOnly {
+		clauses = append(clauses, "events.visible = 1", eventStatusExpression+" = ?")
+		args = append(args, now.Format(time.RFC3339), sports.EventStatus_OPEN)
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	if filter.NextOnly {
+		query = fmt.Sprintf(getEventQueries()[eventsNext], query)
+	}
+
+	return query, args
+}
+
+// applyOrder orders the events by their advertised start time, in the direction requested by
+// the filter or ascending by default. Events without a start time sort last either way. A sort
+// preset or sort fields override the direction.
+func (r *eventsRepo) applyOrder(query string, filter *sports.ListEventsRequestFilter) string {
+	if preset, ok := sortPresets[filter.GetSortPreset()]; ok {
+		return query + " ORDER BY " + preset
+	}
+
+	if len(filter.GetSortBy()) > 0 {
+		return query + " ORDER BY " + sortTerms(filter.SortBy)
+	}
+
+	// Directions are validated along with the rest of the filter.
+	direction, err := order.ParseDirection(filter.GetOrderDirection())
+	if err != nil {
+		direction = order.Asc
+	}
+
+	return query + " ORDER BY CASE WHEN advertised_start_time IS NULL THEN 1 ELSE 0 END, datetime(advertised_start_time) " + direction
+}
+
+// sortTerms returns the ORDER BY terms sorting events by each field in its direction, followed
+// by their ID so that pages never overlap. Fields and directions that aren't allowlisted are
+// skipped, and sorted ascending, respectively.
+func sortTerms(orders []*sports.OrderBy) string {
+	var terms []string
+
+	for _, by := range orders {
+		column, ok := sortColumns[by.GetField()]
+		if !ok {
+			continue
+		}
+
+		direction, err := order.ParseDirection(by.GetDirection().DirectionName())
+		if err != nil {
+			direction = order.Asc
+		}
+
+		terms = append(terms, column+" "+direction)
+	}
+
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+// applyPage limits the ordered events to the page requested by the filter. Events aren't
+// limited unless the filter has a limit, so an offset alone skips events without limiting the
+// rest.
+func (r *eventsRepo) applyPage(query string, args []interface{}, filter *sports.ListEventsRequestFilter) (string, []interface{}) {
+	if filter.GetLimit() <= 0 && filter.GetOffset() <= 0 {
+		return query, args
+	}
+
+	limit := filter.GetLimit()
+	if limit <= 0 {
+		// SQLite only accepts an offset along with a limit, where a negative limit is no limit.
+		limit = -1
+	}
+
+	return query + " LIMIT ? OFFSET ?", append(args, limit, filter.GetOffset())
+}
+
+// statusTime returns the instant event statuses are evaluated at, being the as_of time of the
+// filter when set, otherwise now.
+func statusTime(filter *sports.ListEventsRequestFilter) time.Time {
+	if filter.GetAsOf() == nil {
+		return time.Now()
+	}
+
+	return filter.AsOf.AsTime()
+}
+
+func (r *eventsRepo) scanEvents(rows *sql.Rows) ([]*sports.Event, error) {
+	var events []*sports.Event
+
+	for rows.Next() {
+		var event sports.Event
+		var sportName sql.NullString
+		var sportType sql.NullInt32
+		var advertisedStart sql.NullTime
+
+		if err := rows.Scan(&event.Id, &event.SportId, &sportName, &event.Name, &event.Visible, &advertisedStart, &event.Status, &sportType, &event.Competition, &event.HomeTeam, &event.AwayTeam, &event.Venue); err != nil {
+			return nil, err
+		}
+
+		// Events of sports that don't exist have neither a sport name nor type.
+		event.SportName = sportName.String
+		event.SportType = sports.SportType(sportType.Int32)
+
+		if advertisedStart.Valid {
+			ts, err := ptypes.TimestampProto(advertisedStart.Time)
+			if err != nil {
+				return nil, err
+			}
+
+			event.AdvertisedStartTime = ts
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// testEvent is an event stored by newTestRepo.
+type testEvent struct {
+	id, sportID int64
+	visible     bool
+	start       time.Time
+	competition string
+}
+
+// newTestRepo returns an events repository over a new database in a temporary directory, with
+// its schema migrated and sports seeded, holding just the given events. Each is named after its
+// ID, and those with a zero start time have none.
+func newTestRepo(t *testing.T, events ...testEvent) EventsRepo {
+	t.Helper()
+
+	sportsDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sports.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sportsDB.Close() })
+
+	repo := NewEventsRepo(sportsDB)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sportsDB.Exec(`DELETE FROM events`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range events {
+		var start interface{}
+		if !event.start.IsZero() {
+			start = event.start.Format(time.RFC3339)
+		}
+
+		if _, err := sportsDB.Exec(
+			`INSERT INTO events(id, sport_id, name, visible, advertised_start_time, competition) VALUES (?,?,?,?,?,?)`,
+			event.id,
+			event.sportID,
+			fmt.Sprintf("Event %d", event.id),
+			event.visible,
+			start,
+			event.competition,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return repo
+}
+
+// listIDs returns the IDs of the events listed by the filter, in the order listed.
+func listIDs(t *testing.T, repo EventsRepo, filter *sports.ListEventsRequestFilter) []int64 {
+	t.Helper()
+
+	events, err := repo.List(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+
+	return ids
+}
+
+// sortedIDs returns the IDs in ascending order.
+func sortedIDs(ids []int64) []int64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestListEventsFilters(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+
+	// Sport 1 is soccer, a team sport, while sport 2 is tennis, an individual sport.
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, visible: true, start: start},
+		testEvent{id: 2, sportID: 1, visible: false, start: start},
+		testEvent{id: 3, sportID: 2, visible: true, start: start},
+		testEvent{id: 4, sportID: 3, visible: true, start: start},
+	)
+
+	after, _ := ptypes.TimestampProto(start.Add(-time.Minute))
+	before, _ := ptypes.TimestampProto(start.Add(time.Minute))
+
+	tests := []struct {
+		name   string
+		filter *sports.ListEventsRequestFilter
+		want   []int64
+	}{
+		{name: "no filter", filter: nil, want: []int64{1, 2, 3, 4}},
+		{name: "sport IDs", filter: &sports.ListEventsRequestFilter{SportIds: []int64{1, 2}}, want: []int64{1, 2, 3}},
+		{name: "sport names ignoring case", filter: &sports.ListEventsRequestFilter{SportNames: []string{"tennis"}}, want: []int64{3}},
+		{name: "sport types", filter: &sports.ListEventsRequestFilter{SportTypes: []sports.SportType{sports.SportType_INDIVIDUAL}}, want: []int64{3}},
+		{name: "visible only", filter: &sports.ListEventsRequestFilter{VisibleOnly: true}, want: []int64{1, 3, 4}},
+		{name: "combined", filter: &sports.ListEventsRequestFilter{SportIds: []int64{1}, VisibleOnly: true}, want: []int64{1}},
+		{name: "start time window", filter: &sports.ListEventsRequestFilter{StartTimeAfter: after, StartTimeBefore: before}, want: []int64{1, 2, 3, 4}},
+		{name: "start time window missed", filter: &sports.ListEventsRequestFilter{StartTimeAfter: before}, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sortedIDs(listIDs(t, repo, tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEventsOrder(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, start: start.Add(time.Hour), competition: "NRL"},
+		testEvent{id: 2, sportID: 1, start: start, competition: "AFL"},
+		testEvent{id: 3, sportID: 1, start: start.Add(2 * time.Hour), competition: "NBL"},
+	)
+
+	tests := []struct {
+		name   string
+		filter *sports.ListEventsRequestFilter
+		want   []int64
+	}{
+		{name: "ascending by default", filter: &sports.ListEventsRequestFilter{}, want: []int64{2, 1, 3}},
+		{name: "descending", filter: &sports.ListEventsRequestFilter{OrderDirection: "desc"}, want: []int64{3, 1, 2}},
+		{
+			name: "sort fields",
+			filter: &sports.ListEventsRequestFilter{SortBy: []*sports.OrderBy{
+				{Field: sports.OrderField_COMPETITION, Direction: sports.OrderDirection_DESC},
+			}},
+			want: []int64{1, 3, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventStatusFollowsStartTime(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, start: now.Add(-time.Minute)},
+		testEvent{id: 2, sportID: 1, start: now.Add(time.Minute)},
+	)
+
+	tests := []struct {
+		name string
+		asOf time.Time
+		want map[int64]sports.EventStatus
+	}{
+		{name: "now", asOf: now, want: map[int64]sports.EventStatus{1: sports.EventStatus_CLOSED, 2: sports.EventStatus_OPEN}},
+		{name: "before either", asOf: now.Add(-time.Hour), want: map[int64]sports.EventStatus{1: sports.EventStatus_OPEN, 2: sports.EventStatus_OPEN}},
+		{name: "after both", asOf: now.Add(time.Hour), want: map[int64]sports.EventStatus{1: sports.EventStatus_CLOSED, 2: sports.EventStatus_CLOSED}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf, _ := ptypes.TimestampProto(tt.asOf)
+
+			events, err := repo.List(&sports.ListEventsRequestFilter{AsOf: asOf})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[int64]sports.EventStatus, len(events))
+			for _, event := range events {
+				got[event.Id] = event.Status
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() statuses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Get derives the status as at now.
+	event, err := repo.Get(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetStatus() != sports.EventStatus_OPEN {
+		t.Errorf("Get() status = %v, want OPEN", event.GetStatus())
+	}
+}
+
+func TestListEventsClosingWithin(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, start: now},
+		testEvent{id: 2, sportID: 1, start: now.Add(time.Second)},
+		testEvent{id: 3, sportID: 1, start: now.Add(time.Minute)},
+		testEvent{id: 4, sportID: 1, start: now.Add(time.Minute + time.Second)},
+		testEvent{id: 5, sportID: 1, start: now.Add(-time.Minute)},
+	)
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	tests := []struct {
+		name    string
+		seconds int64
+		want    []int64
+	}{
+		// Events starting at as_of have closed, while those starting a minute later are in time.
+		{name: "a minute", seconds: 60, want: []int64{2, 3}},
+		{name: "a second", seconds: 1, want: []int64{2}},
+		{name: "unset", want: []int64{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &sports.ListEventsRequestFilter{AsOf: asOf, ClosingWithinSeconds: tt.seconds}
+			if got := sortedIDs(listIDs(t, repo, filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEventsNextOnly(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, visible: true, start: now.Add(-time.Minute)},
+		testEvent{id: 2, sportID: 1, visible: false, start: now.Add(time.Minute)},
+		testEvent{id: 3, sportID: 2, visible: true, start: now.Add(2 * time.Minute)},
+		testEvent{id: 4, sportID: 1, visible: true, start: now.Add(3 * time.Minute)},
+	)
+
+	tests := []struct {
+		name   string
+		asOf   time.Time
+		filter *sports.ListEventsRequestFilter
+		want   []int64
+	}{
+		// The closed and hidden events are passed over, whatever their sport.
+		{name: "across sports", asOf: now, filter: &sports.ListEventsRequestFilter{NextOnly: true}, want: []int64{3}},
+		{name: "of a sport", asOf: now, filter: &sports.ListEventsRequestFilter{NextOnly: true, SportIds: []int64{1}}, want: []int64{4}},
+		{name: "none open", asOf: now.Add(time.Hour), filter: &sports.ListEventsRequestFilter{NextOnly: true}, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.AsOf, _ = ptypes.TimestampProto(tt.asOf)
+			if got := listIDs(t, repo, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEventsLeaguePreset(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo := newTestRepo(t,
+		testEvent{id: 1, sportID: 1, start: now.Add(-2 * time.Hour)},
+		testEvent{id: 2, sportID: 1, start: now.Add(2 * time.Hour)},
+		testEvent{id: 3, sportID: 1},
+		testEvent{id: 4, sportID: 1, start: now.Add(-time.Hour)},
+		testEvent{id: 5, sportID: 1, start: now.Add(time.Hour)},
+		testEvent{id: 6, sportID: 1},
+	)
+
+	asOf, _ := ptypes.TimestampProto(now)
+
+	// Upcoming events soonest first, then finished events most recent first, then events without
+	// a start time by ID.
+	filter := &sports.ListEventsRequestFilter{AsOf: asOf, SortPreset: sports.SortPreset_LEAGUE}
+	if got, want := listIDs(t, repo, filter), []int64{5, 2, 4, 1, 3, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() IDs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+const (
+	eventsList           = "list"
+	eventsNext           = "next"
+	eventsCount          = "count"
+	eventsSeedSportTypes = "seed_sport_types"
+	eventsSeedDetails    = "seed_details"
+)
+
+func getEventQueries() map[string]string {
+	return map[string]string{
+		// The placeholder of the status column is bound to the instant statuses are evaluated at.
+		eventsList: `
+			SELECT 
+				events.id AS id, 
+				events.sport_id AS sport_id, 
+				sports.name AS sport_name, 
+				events.name AS name, 
+				events.visible AS visible, 
+				events.advertised_start_time AS advertised_start_time, 
+				` + eventStatusExpression + ` AS status, 
+				sports.type AS sport_type, 
+				events.competition AS competition, 
+				events.home_team AS home_team, 
+				events.away_team AS away_team, 
+				events.venue AS venue 
+			FROM events
+			LEFT JOIN sports ON sports.id = events.sport_id
+		`,
+		// Wraps a (filtered) events query, keeping only the soonest starting event.
+		eventsNext: `
+			SELECT * FROM (
+				SELECT * FROM (%s) ORDER BY datetime(advertised_start_time), id LIMIT 1
+			)
+		`,
+		// Wraps a (filtered) events query, counting its events.
+		eventsCount: `
+			SELECT COUNT(*) FROM (%s)
+		`,
+		// Gives every sport without a type the type of the seeded sport with its ID.
+		eventsSeedSportTypes: `
+			UPDATE sports SET type = ` + seedSportTypes() + ` WHERE type = 0
+		`,
+		// Gives every event without details a competition and venue of its sport, naming the
+		// teams playing after those in its name.
+		eventsSeedDetails: `
+			UPDATE events SET 
+				competition = ` + seedSportChoice(func(sport seededSport) []string { return sport.competitions }) + `, 
+				venue = ` + seedSportChoice(func(sport seededSport) []string { return sport.venues }) + `, 
+				home_team = CASE WHEN instr(name, ' vs ') > 0 THEN substr(name, 1, instr(name, ' vs ') - 1) ELSE '' END, 
+				away_team = CASE WHEN instr(name, ' vs ') > 0 THEN substr(name, instr(name, ' vs ') + 4) ELSE '' END 
+			WHERE competition = ''
+		`,
+	}
+}
+
+// eventStatusExpression derives the status of an event in SQL, as at the instant its placeholder
+// is bound to, in the same way race statuses are derived.
+var eventStatusExpression = fmt.Sprintf(
+	"(CASE WHEN events.advertised_start_time IS NULL THEN %d WHEN datetime(events.advertised_start_time) > datetime(?) THEN %d ELSE %d END)",
+	sports.EventStatus_EVENT_STATUS_UNSPECIFIED,
+	sports.EventStatus_OPEN,
+	sports.EventStatus_CLOSED,
+)
+
+// sortPresets maps each sort preset to the fixed expression events are ordered by.
+var sortPresets = map[sports.SortPreset]string{
+	// Events have no in play status, so the league ordering starts with upcoming events.
+	sports.SortPreset_LEAGUE: fmt.Sprintf(
+		"CASE status WHEN %d THEN 0 WHEN %d THEN 1 ELSE 2 END, "+
+			"CASE WHEN status = %d THEN datetime(advertised_start_time) END ASC, "+
+			"datetime(advertised_start_time) DESC, id",
+		sports.EventStatus_OPEN,
+		sports.EventStatus_CLOSED,
+		sports.EventStatus_OPEN,
+	),
+}
+
+// sortColumns allowlists the expressions events may be sorted by, keyed by the field a filter
+// sorts them by. Events without a start time sort last by it, whatever the direction.
+var sortColumns = map[sports.OrderField]string{
+	sports.OrderField_START_TIME:  "advertised_start_time IS NULL, datetime(advertised_start_time)",
+	sports.OrderField_NAME:        "name",
+	sports.OrderField_COMPETITION: "competition",
+}
+
+// seedChoice returns the SQL expression choosing one of the SQL literals by the integer column,
+// in turn.
+func seedChoice(column string, values []string) string {
+	var b strings.Builder
+
+	b.WriteString("CASE " + column + " % " + strconv.Itoa(len(values)))
+	for i, value := range values {
+		fmt.Fprintf(&b, " WHEN %d THEN %s", i, value)
+	}
+	b.WriteString(" END")
+
+	return b.String()
+}
+
+// seedSportChoice returns the SQL expression choosing, for each event, one of the values of its
+// seeded sport by the event ID, or an empty string when it isn't of a seeded sport. Values are
+// trusted constants, so they're quoted without escaping.
+func seedSportChoice(values func(seededSport) []string) string {
+	var b strings.Builder
+
+	b.WriteString("CASE sport_id")
+	for i, sport := range seededSports {
+		var quoted []string
+		for _, value := range values(sport) {
+			quoted = append(quoted, "'"+value+"'")
+		}
+
+		fmt.Fprintf(&b, " WHEN %d THEN %s", i+1, seedChoice("id", quoted))
+	}
+	b.WriteString(" ELSE '' END")
+
+	return b.String()
+}
+
+// seedSportTypes returns the SQL expression of the type of each seeded sport, by its ID.
+func seedSportTypes() string {
+	var b strings.Builder
+
+	b.WriteString("CASE id")
+	for i, sport := range seededSports {
+		fmt.Fprintf(&b, " WHEN %d THEN %d", i+1, sport.sportType)
+	}
+	b.WriteString(" ELSE 0 END")
+
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/metrics"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"git.neds.sh/matty/entain/sports/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+var (
+	grpcEndpoint    = flag.String("grpc-endpoint", "localhost:9001", "gRPC server endpoint")
+	maxOpenConns    = flag.Int("db-max-open-conns", 0, "Maximum open database connections, or 0 for no limit")
+	maxIdleConns    = flag.Int("db-max-idle-conns", 2, "Maximum idle database connections kept for reuse, or 0 to keep none")
+	connMaxLifetime = flag.Duration("db-conn-max-lifetime", 0, "How long a database connection is reused before it's closed, or 0 to reuse it indefinitely")
+	tlsCertFile     = flag.String("tls-cert-file", "", "PEM certificate to serve gRPC over TLS with, or empty to serve plaintext")
+	tlsKeyFile      = flag.String("tls-key-file", "", "PEM private key of -tls-cert-file")
+	tlsClientCAFile = flag.String("tls-client-ca-file", "", "PEM CA certificates client certificates must be signed by, or empty to not require them")
+	metricsEndpoint = flag.String("metrics-endpoint", "", "Endpoint serving Prometheus metrics at /metrics, or empty to disable")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "How long RPCs in flight have to finish on shutdown, before they're cancelled")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		log.Fatalf("failed running grpc server: %s\n", err)
+	}
+}
+
+func run() error {
+	conn, err := net.Listen("tcp", *grpcEndpoint)
+	if err != nil {
+		return err
+	}
+
+	sportsDB, err := sql.Open("sqlite3", "./db/sports.db")
+	if err != nil {
+		return err
+	}
+
+	sportsDB.SetMaxOpenConns(*maxOpenConns)
+	sportsDB.SetMaxIdleConns(*maxIdleConns)
+	sportsDB.SetConnMaxLifetime(*connMaxLifetime)
+
+	eventsRepo := db.NewEventsRepo(sportsDB)
+	if err := eventsRepo.Init(); err != nil {
+		return err
+	}
+
+	// RPCs are only recorded when metrics are served, but they're logged regardless.
+	var rpcMetrics *metrics.RPCMetrics
+
+	if *metricsEndpoint != "" {
+		rpcMetrics = metrics.NewRPCMetrics()
+		if err := prometheus.Register(rpcMetrics); err != nil {
+			return err
+		}
+
+		go serveMetrics(*metricsEndpoint)
+	}
+
+	serverOpts, err := serverCredentials(*tlsCertFile, *tlsKeyFile, *tlsClientCAFile)
+	if err != nil {
+		return err
+	}
+
+	// Every RPC is observed, including those rejected by the interceptors after it.
+	grpcServer := grpc.NewServer(append(
+		serverOpts,
+		grpc.ChainUnaryInterceptor(service.NewObserver(rpcMetrics), service.NewValidator(), service.NewServerClock()),
+	)...)
+
+	sports.RegisterSportsServer(
+		grpcServer,
+		service.NewSportsService(
+			eventsRepo,
+		),
+	)
+
+	log.Printf("gRPC server listening on: %s\n", *grpcEndpoint)
+
+	// The server drains once it's asked to terminate.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return serve(ctx, grpcServer, conn, eventsRepo, *shutdownTimeout)
+}
+
+// serveMetrics serves the registered Prometheus metrics at /metrics on the endpoint.
+func serveMetrics(endpoint string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Printf("metrics server listening on: %s\n", endpoint)
+
+	if err := http.ListenAndServe(endpoint, mux); err != nil {
+		log.Printf("failed serving metrics: %s\n", err)
+	}
+}
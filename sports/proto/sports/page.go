@@ -0,0 +1,28 @@
+package sports
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+)
+
+// EncodePageToken returns the opaque page token resuming a listing at the offset.
+func EncodePageToken(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+// DecodePageToken returns the offset the page token resumes a listing at, or an error if it
+// isn't a token returned by EncodePageToken.
+func DecodePageToken(token string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errors.New("malformed page token")
+	}
+
+	offset, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errors.New("malformed page token")
+	}
+
+	return offset, nil
+}
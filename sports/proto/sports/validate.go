@@ -0,0 +1,79 @@
+package sports
+
+import (
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"git.neds.sh/matty/entain/order"
+)
+
+// Validate returns an InvalidArgument error if the request is malformed.
+func (x *ListEventsRequest) Validate() error {
+	if x.GetPageToken() != "" {
+		if _, err := DecodePageToken(x.PageToken); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid page_token: %s", err)
+		}
+	}
+
+	return x.GetFilter().Validate()
+}
+
+// Validate returns an InvalidArgument error if the filter is malformed. An absent filter is
+// valid, listing events by default.
+func (x *ListEventsRequestFilter) Validate() error {
+	if x.GetAsOf() != nil {
+		if _, err := ptypes.Timestamp(x.AsOf); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid as_of: %s", err)
+		}
+	}
+
+	for _, sportType := range x.GetSportTypes() {
+		if _, ok := SportType_name[int32(sportType)]; !ok || sportType == SportType_SPORT_TYPE_UNSPECIFIED {
+			return status.Errorf(codes.InvalidArgument, "invalid sport_types: %d", sportType)
+		}
+	}
+
+	if x.GetOffset() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid offset: %d", x.Offset)
+	}
+
+	if _, err := order.ParseDirection(x.GetOrderDirection()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid order_direction: %s", err)
+	}
+
+	if len(x.GetSortBy()) > 0 {
+		if x.OrderDirection != "" || x.SortPreset != SortPreset_SORT_PRESET_UNSPECIFIED {
+			return status.Error(codes.InvalidArgument, "sort_by can't be combined with order_direction or sort_preset")
+		}
+
+		terms := make([]order.Term, len(x.SortBy))
+		for i, by := range x.SortBy {
+			terms[i] = order.Term{Field: by.GetField().String(), Direction: by.GetDirection().DirectionName()}
+		}
+
+		// Each field may be sorted by once.
+		if err := order.ValidateOrderTerms(terms, sortFields, len(sortFields)); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid sort_by: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// sortFields are the names of the fields events may be sorted by.
+var sortFields = []string{
+	OrderField_name[int32(OrderField_START_TIME)],
+	OrderField_name[int32(OrderField_NAME)],
+	OrderField_name[int32(OrderField_COMPETITION)],
+}
+
+// DirectionName returns the name of the direction as parsed by order.ParseDirection, being empty
+// when unspecified so that it defaults to ascending.
+func (x OrderDirection) DirectionName() string {
+	if x == OrderDirection_ORDER_DIRECTION_UNSPECIFIED {
+		return ""
+	}
+
+	return x.String()
+}
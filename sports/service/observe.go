@@ -0,0 +1,75 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"time"
+
+	"git.neds.sh/matty/entain/sports/metrics"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata is the request metadata carrying the ID a request is logged under, as
+// propagated by the API gateway from the HTTP request it serves.
+const RequestIDMetadata = "x-request-id"
+
+// requestIDPattern matches the request IDs accepted from clients, which are otherwise replaced
+// so that they can't forge log lines.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewObserver returns an interceptor logging each RPC as it ends, with its method, status
+// code, duration and request ID, and recording it in the metrics unless they're nil. The
+// request ID is that sent in the x-request-id metadata, or a new one when the client sent none.
+func NewObserver(rpcMetrics *metrics.RPCMetrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		id := requestID(ctx)
+
+		resp, err := handler(ctx, req)
+		observe(rpcMetrics, info.FullMethod, id, err, time.Since(start))
+
+		return resp, err
+	}
+}
+
+// requestID returns the ID of the request of the context, being that sent by the client if it's
+// acceptable, or otherwise a new one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadata); len(values) > 0 && requestIDPattern.MatchString(values[0]) {
+			return values[0]
+		}
+	}
+
+	return newRequestID()
+}
+
+// newRequestID returns a random request ID, as 32 hex digits.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// observe logs an RPC of the method that ended with the error after the elapsed time, as
+// logfmt, and records it in the metrics.
+func observe(rpcMetrics *metrics.RPCMetrics, method, id string, err error, elapsed time.Duration) {
+	code := status.Code(err)
+
+	rpcMetrics.Observe(method, code, elapsed)
+
+	if err != nil {
+		log.Printf("rpc method=%s code=%s duration=%s request_id=%s error=%q\n", method, code, elapsed, id, status.Convert(err).Message())
+		return
+	}
+
+	log.Printf("rpc method=%s code=%s duration=%s request_id=%s\n", method, code, elapsed, id)
+}
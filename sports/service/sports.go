@@ -0,0 +1,92 @@
+package service
+
+import (
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Sports interface {
+	// ListEvents will return a collection of events.
+	ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error)
+
+	// GetEvent will return a single event.
+	GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.Event, error)
+}
+
+const (
+	// defaultPageSize is the most events listed when the filter doesn't ask for a limit.
+	defaultPageSize = 100
+
+	// maxPageSize is the most events listed, whatever the limit the filter asks for.
+	maxPageSize = 1000
+)
+
+// sportsService implements the Sports interface.
+type sportsService struct {
+	eventsRepo db.EventsRepo
+}
+
+// NewSportsService instantiates and returns a new sportsService.
+func NewSportsService(eventsRepo db.EventsRepo) Sports {
+	return &sportsService{eventsRepo}
+}
+
+func (s *sportsService) ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error) {
+	if in.Filter == nil {
+		in.Filter = &sports.ListEventsRequestFilter{}
+	}
+
+	in.Filter.Limit = pageSize(in.Filter.Limit)
+
+	// Tokens are validated along with the rest of the request.
+	if in.PageToken != "" {
+		in.Filter.Offset, _ = sports.DecodePageToken(in.PageToken)
+	}
+
+	total, err := s.eventsRepo.Count(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.eventsRepo.List(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &sports.ListEventsResponse{Events: events, Total: total}
+
+	// Events past this page are left for the next.
+	if next := in.Filter.Offset + in.Filter.Limit; next < total {
+		resp.NextPageToken = sports.EncodePageToken(next)
+	}
+
+	return resp, nil
+}
+
+func (s *sportsService) GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.Event, error) {
+	event, err := s.eventsRepo.Get(in.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if event == nil {
+		return nil, status.Errorf(codes.NotFound, "event %d not found", in.Id)
+	}
+
+	return event, nil
+}
+
+// pageSize returns the number of events to list for the requested limit.
+func pageSize(limit int64) int64 {
+	switch {
+	case limit <= 0:
+		return defaultPageSize
+	case limit > maxPageSize:
+		return maxPageSize
+	default:
+		return limit
+	}
+}
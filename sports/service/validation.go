@@ -0,0 +1,26 @@
+package service
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// validator is implemented by requests that can check themselves for malformed fields.
+type validator interface {
+	Validate() error
+}
+
+// NewValidator returns an interceptor rejecting malformed requests before they're handled,
+// with the InvalidArgument error returned by their Validate method. Requests without one are
+// handled as usual.
+func NewValidator() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if v, ok := req.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
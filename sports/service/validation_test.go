@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidatorRejectsBeforeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      interface{}
+		wantCode codes.Code
+	}{
+		{
+			name:     "valid list",
+			req:      &sports.ListEventsRequest{Filter: &sports.ListEventsRequestFilter{OrderDirection: "desc"}},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "unknown order direction",
+			req:      &sports.ListEventsRequest{Filter: &sports.ListEventsRequestFilter{OrderDirection: "UP"}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "negative offset",
+			req:      &sports.ListEventsRequest{Filter: &sports.ListEventsRequestFilter{Offset: -1}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "request without Validate",
+			req:      &sports.GetEventRequest{Id: 1},
+			wantCode: codes.OK,
+		},
+	}
+
+	interceptor := NewValidator()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled bool
+
+			handler := func(context.Context, interface{}) (interface{}, error) {
+				handled = true
+				return nil, nil
+			}
+
+			_, err := interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: "/sports.Sports/Test"}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("interceptor code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+
+			if handled != (tt.wantCode == codes.OK) {
+				t.Errorf("handler called = %t, want %t", handled, tt.wantCode == codes.OK)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"git.neds.sh/matty/entain/sports/service"
+)
+
+func TestServeDrainsOnShutdown(t *testing.T) {
+	sportsDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sports.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventsRepo := db.NewEventsRepo(sportsDB)
+	if err := eventsRepo.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// RPCs are held in flight until released.
+	started, release := make(chan struct{}), make(chan struct{})
+	hold := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if info.FullMethod == "/sports.Sports/ListEvents" {
+			close(started)
+			<-release
+		}
+
+		return handler(ctx, req)
+	}
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(hold))
+	sports.RegisterSportsServer(grpcServer, service.NewSportsService(eventsRepo))
+
+	conn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, grpcServer, conn, eventsRepo, time.Minute) }()
+
+	client, err := grpc.Dial(conn.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	health, err := healthpb.NewHealthClient(client).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health status = %v, want SERVING", health.Status)
+	}
+
+	listed := make(chan error, 1)
+	go func() {
+		_, err := sports.NewSportsClient(client).ListEvents(context.Background(), &sports.ListEventsRequest{})
+		listed <- err
+	}()
+
+	<-started
+	shutdown()
+
+	// The server waits for the RPC in flight, with the database still open for it.
+	select {
+	case err := <-served:
+		t.Fatalf("serve() returned with an RPC in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	if err := <-listed; err != nil {
+		t.Errorf("ListEvents() in flight at shutdown error = %v", err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+
+	if err := sportsDB.Ping(); err == nil {
+		t.Error("database still open after shutdown")
+	}
+}